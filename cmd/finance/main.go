@@ -16,8 +16,10 @@ import (
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
 	"github.com/rkapps/go_finance/core"
+	"github.com/rkapps/go_finance/providers"
 	"github.com/rkapps/go_finance/store"
 	"github.com/rkapps/go_finance/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var fn *core.Finance
@@ -55,18 +57,62 @@ func main() {
 	if err != nil {
 		log.Fatalf("Finance initialization error: %v\n", err)
 	}
+	fn.NotifyURL = os.Getenv("NOTIFY_WEBHOOK_URL")
+	fn.Providers, err = providers.NewRegistry(os.Getenv("MARKET_PROVIDERS"))
+	if err != nil {
+		log.Fatalf("Market providers configuration error: %v\n", err)
+	}
+	if limit, err := strconv.Atoi(os.Getenv("TIINGO_HOURLY_LIMIT")); err == nil && limit > 0 {
+		providers.SetRateLimit(providers.ProviderTiingo, limit, time.Hour)
+	}
 
 	router := mux.NewRouter()
 	router.HandleFunc("/", indexHandler)
 	//test handler
 
 	router.HandleFunc("/activities/import", authHandler(activitiesImportHandler))
+	router.HandleFunc("/activities/duplicates", authHandler(activitiesDuplicatesHandler))
+	router.HandleFunc("/activities/duplicates/{id}/resolve", authHandler(activitiesDuplicateResolveHandler))
+	router.HandleFunc("/activities/{id}/splits", authHandler(activitySplitsHandler))
+
+	router.HandleFunc("/balances", authHandler(balancesHandler))
+	router.HandleFunc("/balances/update", authHandler(balancesUpdateHandler))
+	router.HandleFunc("/balances/{id}/delete", authHandler(balanceDeleteHandler))
+	router.HandleFunc("/networth", authHandler(netWorthHandler))
+
+	router.HandleFunc("/budgets", authHandler(budgetsHandler))
+	router.HandleFunc("/budgets/update", authHandler(budgetsUpdateHandler))
+	router.HandleFunc("/budgets/{id}/delete", authHandler(budgetDeleteHandler))
+	router.HandleFunc("/schedule", authHandler(scheduleHandler))
+	router.HandleFunc("/schedule/update", authHandler(scheduleUpdateHandler))
+	router.HandleFunc("/schedule/{id}/delete", authHandler(scheduleDeleteHandler))
+	router.HandleFunc("/forecast", authHandler(forecastHandler))
 
 	router.HandleFunc("/investments/accounts", authHandler(investmentsAccountsHandler))
+	router.HandleFunc("/investments/accounts/update", authHandler(investmentsAccountsUpdateHandler))
+	router.HandleFunc("/investments/accounts/{id}/delete", authHandler(investmentsAccountDeleteHandler))
+	router.HandleFunc("/investments/contributions", authHandler(investmentsContributionsHandler))
+	router.HandleFunc("/investments/contributions/limits", authHandler(contributionLimitsHandler))
+	router.HandleFunc("/investments/contributions/limits/update", authHandler(contributionLimitsUpdateHandler))
+	router.HandleFunc("/investments/contributions/limits/{id}/delete", authHandler(contributionLimitDeleteHandler))
+	router.HandleFunc("/settings", authHandler(settingsHandler))
+	router.HandleFunc("/settings/update", authHandler(settingsUpdateHandler))
 	router.HandleFunc("/investments/holdings", authHandler(investmentsHoldingsHandler))
+	router.HandleFunc("/investments/holdings/asof", authHandler(investmentsHoldingsAsOfHandler))
 	router.HandleFunc("/investments/lots", authHandler(investmentsLotsHandler))
 	router.HandleFunc("/investments/gainloss", authHandler(investmentsGainLossHandler))
 	router.HandleFunc("/investments/income", authHandler(investmentsIncomeHandler))
+	router.HandleFunc("/investments/performance", authHandler(investmentsPerformanceHandler))
+	router.HandleFunc("/investments/risk", authHandler(investmentsRiskHandler))
+	router.HandleFunc("/investments/correlation", authHandler(investmentsCorrelationHandler))
+	router.HandleFunc("/investments/allocation", authHandler(investmentsAllocationHandler))
+	router.HandleFunc("/investments/targets", authHandler(allocTargetsHandler))
+	router.HandleFunc("/investments/targets/update", authHandler(allocTargetsUpdateHandler))
+	router.HandleFunc("/investments/targets/{id}/delete", authHandler(allocTargetDeleteHandler))
+	router.HandleFunc("/investments/rebalance", authHandler(investmentsRebalanceHandler))
+	router.HandleFunc("/investments/harvest", authHandler(investmentsHarvestHandler))
+	router.HandleFunc("/investments/sale/whatif", authHandler(investmentsSaleWhatIfHandler))
+	router.HandleFunc("/investments/snapshots", authHandler(investmentsSnapshotsHandler))
 
 	router.HandleFunc("/tickers", tickersHandler)
 	router.HandleFunc("/tickers/import", tickersImportHandler)
@@ -77,6 +123,8 @@ func main() {
 	router.HandleFunc("/tickers/updateStocksEOD", updateStocksEODHandler)
 	router.HandleFunc("/tickers/updateNews", updateNewsHandler)
 	router.HandleFunc("/tickers/updateTickers", updateTickersHandler)
+	router.HandleFunc("/tickers/updateFX", updateFXHandler)
+	router.HandleFunc("/tickers/providers", authHandler(tickersProvidersHandler))
 
 	router.HandleFunc("/tickers/updateStocksRealtime", updateStocksRealtimeHandler)
 	router.HandleFunc("/tickers/updateCryptosRealtime", updateCryptosRealtimeHandler)
@@ -86,6 +134,12 @@ func main() {
 	router.HandleFunc("/tickers/{symbol}/news", tickerNewsHandler)
 
 	router.HandleFunc("/transactions/aggregate", authHandler(transactionsAggregateHandler))
+	router.HandleFunc("/transactions/cashflow", authHandler(transactionsCashFlowHandler))
+	router.HandleFunc("/transactions/subscriptions", authHandler(transactionsSubscriptionsHandler))
+	router.HandleFunc("/transactions/anomalies", authHandler(transactionsAnomaliesHandler))
+	router.HandleFunc("/transactions/transfers", authHandler(transactionsTransfersHandler))
+	router.HandleFunc("/transactions/transfers/match", authHandler(transactionsTransfersMatchHandler))
+	router.HandleFunc("/transactions/transfers/{id}/resolve", authHandler(transactionsTransferResolveHandler))
 
 	log.Printf("Listening on port %s", port)
 	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%s", port),
@@ -127,180 +181,1061 @@ func authHandler(f func(w http.ResponseWriter, r *http.Request)) func(w http.Res
 			return
 		}
 
-		// fmt.Printf("Token: %v\n", authToken.UID)
-		ctx := context.WithValue(r.Context(), store.UserContextUID, store.User{UID: authToken.UID})
+		// fmt.Printf("Token: %v\n", authToken.UID)
+		ctx := context.WithValue(r.Context(), store.UserContextUID, store.User{UID: authToken.UID})
+
+		f(w, r.WithContext(ctx))
+	}
+
+}
+
+// activitiesImportHandler handles imported activities
+func activitiesImportHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	log.Printf("Activities Import - Values: %v\n", values)
+
+	actyType := values["actyType"][0]
+	group := ""
+	category := ""
+	if len(values.Get("group")) > 0 {
+		group = values["group"][0]
+	}
+	if len(values.Get("category")) > 0 {
+		category = values["category"][0]
+	}
+
+	var fromDate, toDate time.Time
+	fromDate = utils.DateFromString(values["fromDate"][0])
+	toDate = utils.DateFromString(values["toDate"][0])
+
+	dupDays := core.DuplicateDays
+	if len(values.Get("dupDays")) > 0 {
+		dupDays, _ = strconv.Atoi(values["dupDays"][0])
+	}
+
+	var actvs store.Activities
+	err := json.NewDecoder(r.Body).Decode(&actvs)
+	if err != nil {
+		fmt.Printf("activitiesImportHandler: %v\n", err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	log.Printf("Group: %s Category: %s FromDate: %v ToDate: %v", group, category, fromDate, toDate)
+	// if err == nil {
+	err = fn.ActivitiesImport(r.Context(), actyType, group, category, &fromDate, &toDate, dupDays, actvs)
+	// // }
+
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+	}
+
+	log.Printf("Import activities - %s count: %d\n", actyType, len(actvs))
+
+}
+
+//activitiesDuplicatesHandler returns the suspected duplicate activities
+func activitiesDuplicatesHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	status := store.DupPending
+	if _, ok := values["status"]; ok {
+		status = values.Get("status")
+	}
+
+	dups := fn.ActivitiesDuplicates(r.Context(), status)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(dups); err != nil {
+		panic(err)
+	}
+	fmt.Printf("ActivitiesDuplicatesHandler - Duplicates: %d\n", len(dups))
+}
+
+//activitiesDuplicateResolveHandler merges or ignores a duplicate pair
+func activitiesDuplicateResolveHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	values := r.URL.Query()
+	log.Printf("Resolve Duplicate: %s Values: %v", vars["id"], values)
+
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = fn.ActivitiesDuplicateResolve(r.Context(), id, values.Get("decision"))
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+}
+
+//activitySplitsHandler replaces the split lines of a transaction
+func activitySplitsHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	log.Printf("Activity Splits: %s", vars["id"])
+
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var splits []*store.ActivitySplit
+	err = json.NewDecoder(r.Body).Decode(&splits)
+	if err != nil {
+		fmt.Printf("activitySplitsHandler: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	actv, err := fn.ActivitySplitsUpdate(r.Context(), id, splits)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(actv); err != nil {
+		panic(err)
+	}
+}
+
+//balancesHandler returns the balances of an account or all accounts
+func balancesHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	account := ""
+	if len(values.Get("account")) > 0 {
+		account = values["account"][0]
+	}
+
+	bals := fn.Balances(r.Context(), account)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(bals); err != nil {
+		panic(err)
+	}
+}
+
+//balancesUpdateHandler adds or updates balances and valuations
+func balancesUpdateHandler(w http.ResponseWriter, r *http.Request) {
+
+	var bals store.Balances
+	err := json.NewDecoder(r.Body).Decode(&bals)
+	if err != nil {
+		fmt.Printf("balancesUpdateHandler: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = fn.MDB.BalancesUpdate(r.Context(), bals)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(bals); err != nil {
+		panic(err)
+	}
+	log.Printf("Update balances - count: %d\n", len(bals))
+}
+
+func balanceDeleteHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	log.Printf("Delete Balance: %s", vars["id"])
+
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = fn.MDB.DeleteBalance(r.Context(), id)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+}
+
+//netWorthHandler returns the net worth by month
+func netWorthHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	months := 0
+	if len(values.Get("months")) > 0 {
+		months, _ = strconv.Atoi(values["months"][0])
+	}
+
+	nws, err := fn.NetWorth(r.Context(), months)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(nws); err != nil {
+		panic(err)
+	}
+}
+
+func budgetsHandler(w http.ResponseWriter, r *http.Request) {
+
+	budgets := fn.Budgets(r.Context())
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(budgets); err != nil {
+		panic(err)
+	}
+}
+
+//budgetsUpdateHandler adds or updates monthly budgets
+func budgetsUpdateHandler(w http.ResponseWriter, r *http.Request) {
+
+	var budgets store.Budgets
+	err := json.NewDecoder(r.Body).Decode(&budgets)
+	if err != nil {
+		fmt.Printf("budgetsUpdateHandler: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = fn.MDB.BudgetsUpdate(r.Context(), budgets)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(budgets); err != nil {
+		panic(err)
+	}
+}
+
+func budgetDeleteHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	log.Printf("Delete Budget: %s", vars["id"])
+
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = fn.MDB.DeleteBudget(r.Context(), id)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+}
+
+func scheduleHandler(w http.ResponseWriter, r *http.Request) {
+
+	items := fn.ScheduledItems(r.Context())
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		panic(err)
+	}
+}
+
+//scheduleUpdateHandler adds or updates scheduled one-off items
+func scheduleUpdateHandler(w http.ResponseWriter, r *http.Request) {
+
+	var items store.ScheduledItems
+	err := json.NewDecoder(r.Body).Decode(&items)
+	if err != nil {
+		fmt.Printf("scheduleUpdateHandler: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = fn.MDB.ScheduledItemsUpdate(r.Context(), items)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(items); err != nil {
+		panic(err)
+	}
+}
+
+func scheduleDeleteHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	log.Printf("Delete Scheduled Item: %s", vars["id"])
+
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = fn.MDB.DeleteScheduledItem(r.Context(), id)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+}
+
+//forecastHandler returns the projected balances of the bank accounts
+func forecastHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	months := 0
+	threshold := 0.0
+	if len(values.Get("months")) > 0 {
+		months, _ = strconv.Atoi(values["months"][0])
+	}
+	if len(values.Get("threshold")) > 0 {
+		threshold, _ = strconv.ParseFloat(values["threshold"][0], 64)
+	}
+
+	log.Printf("Forecast Query Values: %v", values)
+	fcs, err := fn.Forecast(r.Context(), months, threshold)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(fcs); err != nil {
+		panic(err)
+	}
+}
+
+//investmentsAccountsHandler returns the investment accounts
+func investmentsAccountsHandler(w http.ResponseWriter, r *http.Request) {
+
+	log.Printf("Investment accounts")
+	accts, err := fn.InvestmentsAccounts(r.Context())
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(accts); err != nil {
+		panic(err)
+	}
+}
+
+//investmentsAccountsUpdateHandler adds or updates investment accounts
+func investmentsAccountsUpdateHandler(w http.ResponseWriter, r *http.Request) {
+
+	var accts store.InvAccounts
+	err := json.NewDecoder(r.Body).Decode(&accts)
+	if err != nil {
+		fmt.Printf("investmentsAccountsUpdateHandler: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = fn.InvestmentsAccountsUpdate(r.Context(), accts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(accts); err != nil {
+		panic(err)
+	}
+	log.Printf("Update investment accounts - count: %d\n", len(accts))
+}
+
+func investmentsAccountDeleteHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	log.Printf("Delete Investment Account: %s", vars["id"])
+
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = fn.InvestmentsAccountDelete(r.Context(), id)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+}
+
+//investmentsContributionsHandler returns the contributions for the tax year against the limits
+func investmentsContributionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	year := 0
+	if len(values.Get("year")) > 0 {
+		year, _ = strconv.Atoi(values["year"][0])
+	}
+
+	log.Printf("Contributions Query Values: %v", values)
+
+	cs, err := fn.Contributions(r.Context(), int32(year))
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(cs); err != nil {
+		panic(err)
+	}
+}
+
+//contributionLimitsHandler returns the contribution limits for the year
+func contributionLimitsHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	year := time.Now().Year()
+	if len(values.Get("year")) > 0 {
+		year, _ = strconv.Atoi(values["year"][0])
+	}
+
+	limits := fn.ContributionLimits(r.Context(), int32(year))
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(limits); err != nil {
+		panic(err)
+	}
+}
+
+//contributionLimitsUpdateHandler adds or updates the configured contribution limits
+func contributionLimitsUpdateHandler(w http.ResponseWriter, r *http.Request) {
+
+	var limits store.ContributionLimits
+	err := json.NewDecoder(r.Body).Decode(&limits)
+	if err != nil {
+		fmt.Printf("contributionLimitsUpdateHandler: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = fn.ContributionLimitsUpdate(r.Context(), limits)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(limits); err != nil {
+		panic(err)
+	}
+	log.Printf("Update contribution limits - count: %d\n", len(limits))
+}
+
+func contributionLimitDeleteHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	log.Printf("Delete Contribution Limit: %s", vars["id"])
+
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = fn.ContributionLimitDelete(r.Context(), id)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+}
+
+//settingsHandler returns the settings of the user
+func settingsHandler(w http.ResponseWriter, r *http.Request) {
+
+	settings := fn.UserSettings(r.Context())
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(settings); err != nil {
+		panic(err)
+	}
+}
+
+//settingsUpdateHandler updates the settings of the user. Settings not in the request keep their values.
+func settingsUpdateHandler(w http.ResponseWriter, r *http.Request) {
+
+	settings := fn.UserSettings(r.Context())
+	err := json.NewDecoder(r.Body).Decode(settings)
+	if err != nil {
+		fmt.Printf("settingsUpdateHandler: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = fn.UserSettingsUpdate(r.Context(), settings)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(settings); err != nil {
+		panic(err)
+	}
+}
+
+func investmentsHoldingsHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	group := ""
+	category := ""
+	if len(values.Get("group")) > 0 {
+		group = values["group"][0]
+	}
+	if len(values.Get("category")) > 0 {
+		category = values["category"][0]
+	}
+
+	byAccount, _ := strconv.ParseBool(values["byAccount"][0])
+	risk := false
+	currency := ""
+	if len(values.Get("risk")) > 0 {
+		risk, _ = strconv.ParseBool(values["risk"][0])
+	}
+	if len(values.Get("currency")) > 0 {
+		currency = values["currency"][0]
+	}
+	log.Printf("Values: %v\n", values)
+
+	// fn.UpdateStocksRealtime(r.Context())
+	// fn.UpdateCryptosRealtime(r.Context())
+
+	holds, err := fn.InvestmentsHoldings(r.Context(), group, category, byAccount, currency)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+	if risk {
+		_, err = fn.InvestmentsHoldingsRisk(r.Context(), holds, values.Get("period"), values.Get("benchmark"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	fmt.Printf("Holdings: %d\n", len(holds))
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(holds); err != nil {
+		panic(err)
+	}
+}
+
+//investmentsHoldingsAsOfHandler returns the holdings as they stood at the end of the date
+func investmentsHoldingsAsOfHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	group := ""
+	category := ""
+	currency := ""
+	byAccount := false
+	if len(values.Get("date")) == 0 {
+		http.Error(w, "Date is required", http.StatusBadRequest)
+		return
+	}
+	date := utils.DateFromString(values["date"][0])
+	if len(values.Get("group")) > 0 {
+		group = values["group"][0]
+	}
+	if len(values.Get("category")) > 0 {
+		category = values["category"][0]
+	}
+	if len(values.Get("byAccount")) > 0 {
+		byAccount, _ = strconv.ParseBool(values["byAccount"][0])
+	}
+	if len(values.Get("currency")) > 0 {
+		currency = values["currency"][0]
+	}
+	log.Printf("Values: %v\n", values)
+
+	holds, err := fn.InvestmentsHoldingsAsOf(r.Context(), date, group, category, byAccount, currency)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+	fmt.Printf("Holdings as of %s: %d\n", values["date"][0], len(holds))
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(holds); err != nil {
+		panic(err)
+	}
+}
+
+func investmentsLotsHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	group := ""
+	category := ""
+	symbol := ""
+	status := ""
+
+	if len(values.Get("group")) > 0 {
+		group = values["group"][0]
+	}
+	if len(values.Get("category")) > 0 {
+		category = values["category"][0]
+	}
+	if len(values.Get("symbol")) > 0 {
+		symbol = values["symbol"][0]
+	}
+	if len(values.Get("status")) > 0 {
+		status = values["status"][0]
+	}
+
+	lots := fn.InvestmentsLots(r.Context(), group, category, symbol, status)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(lots); err != nil {
+		panic(err)
+	}
+	fmt.Printf("InvestmentsLotsHandler - Lots: %d\n", len(lots))
+}
+
+func investmentsGainLossHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	year, _ := strconv.Atoi(values["year"][0])
+	group := ""
+	category := ""
+	tax := ""
+	currency := ""
+	if len(values.Get("group")) > 0 {
+		group = values["group"][0]
+	}
+	if len(values.Get("category")) > 0 {
+		category = values["category"][0]
+	}
+	if len(values.Get("tax")) > 0 {
+		tax = values["tax"][0]
+	}
+	if len(values.Get("currency")) > 0 {
+		currency = values["currency"][0]
+	}
+
+	ft := time.Date(year, time.Month(1), 1, 0, 0, 0, 0, time.Now().Location())
+	et := time.Date(year, time.Month(12), 31, 24, 0, 0, 0, time.Now().Location())
+
+	log.Printf("investmentsGainLoss Query Values: %v", values)
+
+	lots, err := fn.InvestmentsGainLoss(r.Context(), group, category, tax, currency, ft, et)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(lots); err != nil {
+		panic(err)
+	}
+	fmt.Printf("InvestmentsGainLossHandler - Lots: %d\n", len(lots))
+}
+
+func investmentsIncomeHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	year, _ := strconv.Atoi(values["year"][0])
+	group := ""
+	category := ""
+	tax := ""
+	open := false
+	if len(values.Get("group")) > 0 {
+		group = values["group"][0]
+	}
+	if len(values.Get("category")) > 0 {
+		category = values["category"][0]
+	}
+	if len(values.Get("tax")) > 0 {
+		tax = values["tax"][0]
+	}
+	if len(values.Get("open")) > 0 {
+		open, _ = strconv.ParseBool(values["open"][0])
+	}
+
+	ft := time.Date(year, time.Month(1), 1, 0, 0, 0, 0, time.Now().Location())
+	et := time.Date(year, time.Month(12), 31, 24, 0, 0, 0, time.Now().Location())
+
+	log.Printf("investmentsIncome Query Values: %v", values)
+
+	lots := fn.InvestmentsRewards(r.Context(), group, category, tax, open, ft, et)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(lots); err != nil {
+		panic(err)
+	}
+	fmt.Printf("InvestmentsRewardsHandler - Lots: %d\n", len(lots))
+}
+
+//investmentsPerformanceHandler returns the time-weighted and money-weighted returns for the performance periods compared with the benchmarks
+func investmentsPerformanceHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	level := ""
+	var benchmarks []string
+	if len(values.Get("level")) > 0 {
+		level = values["level"][0]
+	}
+	if len(values.Get("benchmarks")) > 0 {
+		benchmarks = strings.Split(values["benchmarks"][0], ",")
+	}
+
+	log.Printf("investmentsPerformance Query Values: %v", values)
+
+	perfs, err := fn.InvestmentsPerformance(r.Context(), level, benchmarks)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(perfs); err != nil {
+		panic(err)
+	}
+	fmt.Printf("InvestmentsPerformanceHandler - Performances: %d\n", len(perfs))
+}
+
+//investmentsRiskHandler returns the volatility, drawdown, Sharpe, Sortino and beta of the portfolio and its holdings
+func investmentsRiskHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	period := ""
+	benchmark := ""
+	byAccount := false
+	if len(values.Get("period")) > 0 {
+		period = values["period"][0]
+	}
+	if len(values.Get("benchmark")) > 0 {
+		benchmark = values["benchmark"][0]
+	}
+	if len(values.Get("byAccount")) > 0 {
+		byAccount, _ = strconv.ParseBool(values["byAccount"][0])
+	}
+
+	log.Printf("investmentsRisk Query Values: %v", values)
+
+	risk, err := fn.InvestmentsRisk(r.Context(), period, benchmark, byAccount)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(risk); err != nil {
+		panic(err)
+	}
+	fmt.Printf("InvestmentsRiskHandler - Holdings: %d\n", len(risk.Holdings))
+}
+
+//investmentsCorrelationHandler returns the correlations of the returns of the holdings or the symbols
+func investmentsCorrelationHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	var symbols []string
+	lookback := ""
+	frequency := ""
+	window := 0
+	if len(values.Get("symbols")) > 0 {
+		symbols = strings.Split(values["symbols"][0], ",")
+	}
+	if len(values.Get("lookback")) > 0 {
+		lookback = values["lookback"][0]
+	}
+	if len(values.Get("frequency")) > 0 {
+		frequency = values["frequency"][0]
+	}
+	if len(values.Get("window")) > 0 {
+		window, _ = strconv.Atoi(values["window"][0])
+	}
+
+	log.Printf("investmentsCorrelation Query Values: %v", values)
 
-		f(w, r.WithContext(ctx))
+	corr, err := fn.InvestmentsCorrelation(r.Context(), symbols, lookback, frequency, window)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(corr); err != nil {
+		panic(err)
+	}
+	fmt.Printf("InvestmentsCorrelationHandler - Symbols: %d\n", len(corr.Symbols))
 }
 
-// activitiesImportHandler handles imported activities
-func activitiesImportHandler(w http.ResponseWriter, r *http.Request) {
+//investmentsAllocationHandler returns the market value of the holdings by the dimensions with the drill-downs
+func investmentsAllocationHandler(w http.ResponseWriter, r *http.Request) {
 
 	values := r.URL.Query()
-	log.Printf("Activities Import - Values: %v\n", values)
-
-	actyType := values["actyType"][0]
 	group := ""
 	category := ""
+	var by []string
 	if len(values.Get("group")) > 0 {
 		group = values["group"][0]
 	}
 	if len(values.Get("category")) > 0 {
 		category = values["category"][0]
 	}
+	if len(values.Get("by")) > 0 {
+		by = strings.Split(values["by"][0], ",")
+	}
 
-	var fromDate, toDate time.Time
-	fromDate = utils.DateFromString(values["fromDate"][0])
-	toDate = utils.DateFromString(values["toDate"][0])
+	log.Printf("investmentsAllocation Query Values: %v", values)
 
-	var actvs store.Activities
-	err := json.NewDecoder(r.Body).Decode(&actvs)
+	allocs, err := fn.InvestmentsAllocation(r.Context(), group, category, by)
 	if err != nil {
-		fmt.Printf("activitiesImportHandler: %v\n", err)
-		fmt.Fprintf(w, err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	log.Printf("Group: %s Category: %s FromDate: %v ToDate: %v", group, category, fromDate, toDate)
-	// if err == nil {
-	err = fn.ActivitiesImport(r.Context(), actyType, group, category, &fromDate, &toDate, actvs)
-	// // }
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(allocs); err != nil {
+		panic(err)
+	}
+	fmt.Printf("InvestmentsAllocationHandler - Allocations: %d\n", len(allocs))
+}
+
+//allocTargetsHandler returns the target allocations
+func allocTargetsHandler(w http.ResponseWriter, r *http.Request) {
+
+	targets := fn.AllocTargets(r.Context())
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(targets); err != nil {
+		panic(err)
+	}
+}
+
+//allocTargetsUpdateHandler adds or updates the target allocations
+func allocTargetsUpdateHandler(w http.ResponseWriter, r *http.Request) {
 
+	var targets store.AllocTargets
+	err := json.NewDecoder(r.Body).Decode(&targets)
 	if err != nil {
-		fmt.Println(err)
-		fmt.Fprintf(w, err.Error())
+		fmt.Printf("allocTargetsUpdateHandler: %v\n", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	log.Printf("Import activities - %s count: %d\n", actyType, len(actvs))
+	err = fn.AllocTargetsUpdate(r.Context(), targets)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(targets); err != nil {
+		panic(err)
+	}
+	log.Printf("Update target allocations - count: %d\n", len(targets))
 }
 
-func investmentsAccountsHandler(w http.ResponseWriter, r *http.Request) {
-	log.Printf("Investment accounts")
-	fn.MDB.InvestmentsAccounts(r.Context())
+func allocTargetDeleteHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	log.Printf("Delete Target Allocation: %s", vars["id"])
+
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	err = fn.AllocTargetDelete(r.Context(), id)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
 }
 
-func investmentsHoldingsHandler(w http.ResponseWriter, r *http.Request) {
+//investmentsRebalanceHandler returns the drift from the target allocations and the trades that rebalance the holdings
+func investmentsRebalanceHandler(w http.ResponseWriter, r *http.Request) {
 
 	values := r.URL.Query()
-	group := ""
-	category := ""
-	if len(values.Get("group")) > 0 {
-		group = values["group"][0]
+	dimension := ""
+	band := 0.0
+	if len(values.Get("dimension")) > 0 {
+		dimension = values["dimension"][0]
 	}
-	if len(values.Get("category")) > 0 {
-		category = values["category"][0]
+	if len(values.Get("band")) > 0 {
+		band, _ = strconv.ParseFloat(values["band"][0], 64)
 	}
 
-	byAccount, _ := strconv.ParseBool(values["byAccount"][0])
-	log.Printf("Values: %v\n", values)
-
-	// fn.UpdateStocksRealtime(r.Context())
-	// fn.UpdateCryptosRealtime(r.Context())
+	log.Printf("investmentsRebalance Query Values: %v", values)
 
-	holds, err := fn.InvestmentsHoldings(r.Context(), group, category, byAccount)
+	rb, err := fn.InvestmentsRebalance(r.Context(), dimension, band)
 	if err != nil {
-		fmt.Println(err)
-		fmt.Fprintf(w, err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	fmt.Printf("Holdings: %d\n", len(holds))
 
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(holds); err != nil {
+	if err := json.NewEncoder(w).Encode(rb); err != nil {
 		panic(err)
 	}
+	fmt.Printf("InvestmentsRebalanceHandler - Trades: %d\n", len(rb.Trades))
 }
 
-func investmentsLotsHandler(w http.ResponseWriter, r *http.Request) {
+//investmentsHarvestHandler returns the lots with unrealized losses to harvest and the tax they save
+func investmentsHarvestHandler(w http.ResponseWriter, r *http.Request) {
 
 	values := r.URL.Query()
-	group := ""
-	category := ""
-	symbol := ""
-	status := ""
-
-	if len(values.Get("group")) > 0 {
-		group = values["group"][0]
+	minLoss := 0.0
+	minPerc := 0.0
+	if len(values.Get("minLoss")) > 0 {
+		minLoss, _ = strconv.ParseFloat(values["minLoss"][0], 64)
 	}
-	if len(values.Get("category")) > 0 {
-		category = values["category"][0]
-	}
-	if len(values.Get("symbol")) > 0 {
-		symbol = values["symbol"][0]
+	if len(values.Get("minPerc")) > 0 {
+		minPerc, _ = strconv.ParseFloat(values["minPerc"][0], 64)
 	}
-	if len(values.Get("status")) > 0 {
-		status = values["status"][0]
+
+	log.Printf("investmentsHarvest Query Values: %v", values)
+
+	hv, err := fn.InvestmentsHarvest(r.Context(), minLoss, minPerc)
+	if err != nil {
+		fmt.Println(err)
+		fmt.Fprintf(w, err.Error())
+		return
 	}
 
-	lots := fn.InvestmentsLots(r.Context(), group, category, symbol, status)
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(lots); err != nil {
+	if err := json.NewEncoder(w).Encode(hv); err != nil {
 		panic(err)
 	}
-	fmt.Printf("InvestmentsLotsHandler - Lots: %d\n", len(lots))
+	fmt.Printf("InvestmentsHarvestHandler - Lots: %d\n", len(hv.Lots))
 }
 
-func investmentsGainLossHandler(w http.ResponseWriter, r *http.Request) {
+//investmentsSaleWhatIfHandler returns the lots a sale would relieve, the realized gains and the estimated tax
+func investmentsSaleWhatIfHandler(w http.ResponseWriter, r *http.Request) {
 
 	values := r.URL.Query()
-	year, _ := strconv.Atoi(values["year"][0])
-	group := ""
-	category := ""
-	if len(values.Get("group")) > 0 {
-		group = values["group"][0]
+	account := ""
+	symbol := ""
+	qty := 0.0
+	price := 0.0
+	stRate := 0.0
+	ltRate := 0.0
+	if len(values.Get("account")) > 0 {
+		account = values["account"][0]
 	}
-	if len(values.Get("category")) > 0 {
-		category = values["category"][0]
+	if len(values.Get("symbol")) > 0 {
+		symbol = values["symbol"][0]
+	}
+	if len(values.Get("qty")) > 0 {
+		qty, _ = strconv.ParseFloat(values["qty"][0], 64)
+	}
+	if len(values.Get("price")) > 0 {
+		price, _ = strconv.ParseFloat(values["price"][0], 64)
+	}
+	if len(values.Get("stRate")) > 0 {
+		stRate, _ = strconv.ParseFloat(values["stRate"][0], 64)
+	}
+	if len(values.Get("ltRate")) > 0 {
+		ltRate, _ = strconv.ParseFloat(values["ltRate"][0], 64)
 	}
 
-	ft := time.Date(year, time.Month(1), 1, 0, 0, 0, 0, time.Now().Location())
-	et := time.Date(year, time.Month(12), 31, 24, 0, 0, 0, time.Now().Location())
+	log.Printf("investmentsSaleWhatIf Query Values: %v", values)
 
-	log.Printf("investmentsGainLoss Query Values: %v", values)
+	sp, err := fn.InvestmentsSaleProjection(r.Context(), account, symbol, qty, price, stRate, ltRate)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	lots := fn.InvestmentsGainLoss(r.Context(), group, category, ft, et)
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(lots); err != nil {
+	if err := json.NewEncoder(w).Encode(sp); err != nil {
 		panic(err)
 	}
-	fmt.Printf("InvestmentsGainLossHandler - Lots: %d\n", len(lots))
+	fmt.Printf("InvestmentsSaleWhatIfHandler - Lots: %d\n", len(sp.Lots))
 }
 
-func investmentsIncomeHandler(w http.ResponseWriter, r *http.Request) {
+//investmentsSnapshotsHandler returns the daily value of the holdings for the date range
+func investmentsSnapshotsHandler(w http.ResponseWriter, r *http.Request) {
 
 	values := r.URL.Query()
-	year, _ := strconv.Atoi(values["year"][0])
-	group := ""
-	category := ""
-	open := false
-	if len(values.Get("group")) > 0 {
-		group = values["group"][0]
+	var fromDate, toDate *time.Time
+	holdings := false
+	if len(values.Get("fromDate")) > 0 {
+		date := utils.DateFromString(values["fromDate"][0])
+		fromDate = &date
 	}
-	if len(values.Get("category")) > 0 {
-		category = values["category"][0]
+	if len(values.Get("toDate")) > 0 {
+		date := utils.DateFromString(values["toDate"][0])
+		toDate = &date
 	}
-	if len(values.Get("open")) > 0 {
-		open, _ = strconv.ParseBool(values["open"][0])
+	if len(values.Get("holdings")) > 0 {
+		holdings, _ = strconv.ParseBool(values["holdings"][0])
 	}
 
-	ft := time.Date(year, time.Month(1), 1, 0, 0, 0, 0, time.Now().Location())
-	et := time.Date(year, time.Month(12), 31, 24, 0, 0, 0, time.Now().Location())
-
-	log.Printf("investmentsIncome Query Values: %v", values)
+	log.Printf("investmentsSnapshots Query Values: %v", values)
 
-	lots := fn.InvestmentsRewards(r.Context(), group, category, open, ft, et)
+	snaps := fn.InvestmentsSnapshots(r.Context(), fromDate, toDate, holdings)
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	w.WriteHeader(http.StatusOK)
-	if err := json.NewEncoder(w).Encode(lots); err != nil {
+	if err := json.NewEncoder(w).Encode(snaps); err != nil {
 		panic(err)
 	}
-	fmt.Printf("InvestmentsRewardsHandler - Lots: %d\n", len(lots))
+	fmt.Printf("InvestmentsSnapshotsHandler - Snapshots: %d\n", len(snaps))
 }
 
 //tickersImportHandler loads tickers from a csv file
@@ -424,6 +1359,17 @@ func tickersGroupsHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+//tickersProvidersHandler returns the health of the market data providers
+func tickersProvidersHandler(w http.ResponseWriter, r *http.Request) {
+
+	phs := fn.ProvidersHealth()
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(phs); err != nil {
+		panic(err)
+	}
+}
+
 //loadTickersHandler loads tickers from a csv file
 func tickersSearchHandler(w http.ResponseWriter, r *http.Request) {
 
@@ -458,8 +1404,13 @@ func transactionsAggregateHandler(w http.ResponseWriter, r *http.Request) {
 	ft := time.Date(year, time.Month(1), 1, 0, 0, 0, 0, time.Now().Location())
 	et := time.Date(year, time.Month(12), 31, 24, 0, 0, 0, time.Now().Location())
 
+	dbcr := "debit"
+	if _, ok := values["dbcr"]; ok {
+		dbcr = values.Get("dbcr")
+	}
+
 	log.Printf("Values: %v ", values)
-	taggs, err := fn.AggregateTransactions(r.Context(), &ft, &et)
+	taggs, err := fn.AggregateTransactions(r.Context(), dbcr, &ft, &et)
 	if err != nil {
 		fmt.Printf("Error: %v", err)
 		fmt.Fprintf(w, err.Error())
@@ -474,6 +1425,164 @@ func transactionsAggregateHandler(w http.ResponseWriter, r *http.Request) {
 
 }
 
+//transactionsCashFlowHandler returns the monthly cash flow statement for the year
+func transactionsCashFlowHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	year, _ := strconv.Atoi(values["year"][0])
+
+	log.Printf("Cash Flow Query Values: %v", values)
+	cfs, err := fn.TransactionsCashFlow(r.Context(), year)
+	if err != nil {
+		fmt.Printf("Error: %v", err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(cfs); err != nil {
+		panic(err)
+	}
+}
+
+//transactionsSubscriptionsHandler returns the recurring charges detected from transactions
+func transactionsSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	months := 0
+	all := false
+	if len(values.Get("months")) > 0 {
+		months, _ = strconv.Atoi(values["months"][0])
+	}
+	if len(values.Get("all")) > 0 {
+		all, _ = strconv.ParseBool(values["all"][0])
+	}
+
+	log.Printf("Subscriptions Query Values: %v", values)
+
+	subs := fn.TransactionsSubscriptions(r.Context(), months, all)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(subs); err != nil {
+		panic(err)
+	}
+	fmt.Printf("TransactionsSubscriptionsHandler - Subscriptions: %d\n", len(subs))
+}
+
+//transactionsAnomaliesHandler returns the unusual months and transactions of the last days
+func transactionsAnomaliesHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	months := 0
+	days := 0
+	threshold := 0.0
+	notify := false
+	if len(values.Get("months")) > 0 {
+		months, _ = strconv.Atoi(values["months"][0])
+	}
+	if len(values.Get("days")) > 0 {
+		days, _ = strconv.Atoi(values["days"][0])
+	}
+	if len(values.Get("threshold")) > 0 {
+		threshold, _ = strconv.ParseFloat(values["threshold"][0], 64)
+	}
+	if len(values.Get("notify")) > 0 {
+		notify, _ = strconv.ParseBool(values["notify"][0])
+	}
+
+	log.Printf("Anomalies Query Values: %v", values)
+
+	anoms, err := fn.TransactionsAnomalies(r.Context(), months, days, threshold, notify)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(anoms); err != nil {
+		panic(err)
+	}
+	fmt.Printf("TransactionsAnomaliesHandler - Anomalies: %d\n", len(anoms))
+}
+
+//transactionsTransfersHandler returns the transfers with the status, unmatched by default
+func transactionsTransfersHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	status := store.TransferUnmatched
+	if len(values.Get("status")) > 0 {
+		status = values["status"][0]
+	}
+
+	actvs := fn.TransactionsTransfers(r.Context(), status)
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(actvs); err != nil {
+		panic(err)
+	}
+	fmt.Printf("TransactionsTransfersHandler - Transfers: %d\n", len(actvs))
+}
+
+//transactionsTransfersMatchHandler matches the transfers for the year and returns the unmatched ones
+func transactionsTransfersMatchHandler(w http.ResponseWriter, r *http.Request) {
+
+	values := r.URL.Query()
+	year, _ := strconv.Atoi(values["year"][0])
+	days := core.TransferDays
+	if len(values.Get("days")) > 0 {
+		days, _ = strconv.Atoi(values["days"][0])
+	}
+
+	ft := time.Date(year, time.Month(1), 1, 0, 0, 0, 0, time.Now().Location())
+	et := time.Date(year, time.Month(12), 31, 24, 0, 0, 0, time.Now().Location())
+
+	log.Printf("Match Transfers Query Values: %v", values)
+	actvs, err := fn.TransactionsMatchTransfers(r.Context(), ft, et, days)
+	if err != nil {
+		fmt.Printf("Error: %v", err)
+		fmt.Fprintf(w, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(actvs); err != nil {
+		panic(err)
+	}
+}
+
+//transactionsTransferResolveHandler pairs a transfer with pairId or confirms it without a match
+func transactionsTransferResolveHandler(w http.ResponseWriter, r *http.Request) {
+
+	vars := mux.Vars(r)
+	values := r.URL.Query()
+	log.Printf("Resolve Transfer: %s Values: %v", vars["id"], values)
+
+	id, err := primitive.ObjectIDFromHex(vars["id"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pairID := primitive.NilObjectID
+	if len(values.Get("pairId")) > 0 {
+		pairID, err = primitive.ObjectIDFromHex(values["pairId"][0])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	err = fn.TransactionsTransferResolve(r.Context(), id, pairID)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(http.StatusOK)
+}
+
 func updateStocksEODHandler(w http.ResponseWriter, r *http.Request) {
 	fn.UpdateStocksEOD(r.Context())
 }
@@ -482,6 +1591,10 @@ func updateCryptosEODHandler(w http.ResponseWriter, r *http.Request) {
 	fn.UpdateCryptosEOD(r.Context())
 }
 
+func updateFXHandler(w http.ResponseWriter, r *http.Request) {
+	fn.UpdateFXRates(r.Context())
+}
+
 func updateStocksRealtimeHandler(w http.ResponseWriter, r *http.Request) {
 	fn.UpdateStocksRealtime(r.Context())
 }
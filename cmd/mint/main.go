@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/rkapps/go_finance/store"
 	"github.com/rkapps/go_finance/utils"
 )
 
@@ -220,7 +221,11 @@ func getActivityGroupCategory(merchant string, category string, dbcr string) (st
 			group = "Shopping"
 		}
 
-	case "Credit Card Payment", "Transfer", "Deposit", "Cash & ATM":
+	case "Credit Card Payment", "Transfer":
+		//matched across accounts on import, unmatched ones are flagged for review
+		group = store.GroupTransfer
+
+	case "Deposit", "Cash & ATM":
 		group = "Ignore"
 
 	case "Uncategorized":
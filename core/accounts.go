@@ -0,0 +1,193 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//CurrencyUSD is the default currency of an account
+const CurrencyUSD = "USD"
+
+const (
+	//TaxReportTaxable selects the lots in taxable accounts
+	TaxReportTaxable string = "taxable"
+	//TaxReportAdvantaged selects the lots in tax-deferred and tax-exempt accounts
+	TaxReportAdvantaged string = "advantaged"
+	//TaxReportAll selects the lots in all accounts
+	TaxReportAll string = "all"
+)
+
+//InvestmentsAccounts returns the investment accounts.
+//Accounts found in the investment activities that are not registered are added with default details.
+func (fn *Finance) InvestmentsAccounts(ctx context.Context) (store.InvAccounts, error) {
+
+	accts := fn.MDB.InvestmentsAccounts(ctx)
+	am := make(map[string]*store.InvAccount)
+	for _, acct := range accts {
+		am[acct.Account] = acct
+	}
+
+	found, err := fn.MDB.InvestmentsActivityAccounts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var naccts store.InvAccounts
+	for _, acct := range found {
+		if len(acct.Account) == 0 || am[acct.Account] != nil {
+			continue
+		}
+		setAccountDefaults(acct)
+		am[acct.Account] = acct
+		naccts = append(naccts, acct)
+	}
+
+	if len(naccts) > 0 {
+		if err := fn.MDB.InvestmentsAccountsUpdate(ctx, naccts); err != nil {
+			return nil, err
+		}
+		accts = fn.MDB.InvestmentsAccounts(ctx)
+	}
+	if accts == nil {
+		accts = store.InvAccounts{}
+	}
+	return accts, nil
+}
+
+//InvestmentsAccountsUpdate validates and updates the investment accounts
+func (fn *Finance) InvestmentsAccountsUpdate(ctx context.Context, accts store.InvAccounts) error {
+
+	am := make(map[string]*store.InvAccount)
+	for _, acct := range fn.MDB.InvestmentsAccounts(ctx) {
+		am[acct.Account] = acct
+	}
+
+	for _, acct := range accts {
+		if len(acct.Account) == 0 {
+			return errors.New("Account name is required")
+		}
+		if prev := am[acct.Account]; prev != nil && prev.ID != acct.ID {
+			return fmt.Errorf("Account %s already exists", acct.Account)
+		}
+		if len(acct.Type) > 0 && len(accountTaxTreatment(acct.Type)) == 0 {
+			return fmt.Errorf("Account type %s is not valid", acct.Type)
+		}
+		switch acct.TaxTreatment {
+		case "", store.TaxTaxable, store.TaxDeferred, store.TaxExempt:
+		default:
+			return fmt.Errorf("Tax treatment %s is not valid", acct.TaxTreatment)
+		}
+		switch acct.Status {
+		case "", store.AcctOpen, store.AcctClosed:
+		default:
+			return fmt.Errorf("Account status %s is not valid", acct.Status)
+		}
+		switch acct.CostBasis {
+		case "", store.BasisFIFO, store.BasisLIFO, store.BasisHIFO:
+		default:
+			return fmt.Errorf("Cost basis %s is not valid", acct.CostBasis)
+		}
+		setAccountDefaults(acct)
+	}
+
+	return fn.MDB.InvestmentsAccountsUpdate(ctx, accts)
+}
+
+//InvestmentsAccountDelete deletes the investment account
+func (fn *Finance) InvestmentsAccountDelete(ctx context.Context, id primitive.ObjectID) error {
+	return fn.MDB.DeleteInvestmentsAccount(ctx, id)
+}
+
+//investmentsAccountsMap returns the registered investment accounts by account name
+func (fn *Finance) investmentsAccountsMap(ctx context.Context) map[string]*store.InvAccount {
+
+	am := make(map[string]*store.InvAccount)
+	for _, acct := range fn.MDB.InvestmentsAccounts(ctx) {
+		am[acct.Account] = acct
+	}
+	return am
+}
+
+//taxLots sets the tax treatment of the lots from their accounts and returns the lots selected by the tax report.
+//Lots in taxable accounts are returned by default. Accounts not registered are treated as taxable.
+func (fn *Finance) taxLots(ctx context.Context, lots store.InvLots, tax string) store.InvLots {
+
+	am := fn.investmentsAccountsMap(ctx)
+	tlots := store.InvLots{}
+	for _, lot := range lots {
+		lot.TaxTreatment = store.TaxTaxable
+		if acct := am[lot.Account]; acct != nil && len(acct.TaxTreatment) > 0 {
+			lot.TaxTreatment = acct.TaxTreatment
+		}
+		taxable := strings.Compare(lot.TaxTreatment, store.TaxTaxable) == 0
+		switch tax {
+		case TaxReportAll:
+		case TaxReportAdvantaged:
+			if taxable {
+				continue
+			}
+		default:
+			if !taxable {
+				continue
+			}
+		}
+		tlots = append(tlots, lot)
+	}
+	return tlots
+}
+
+//lotOrder returns the order lots are relieved in for the cost basis elected for the account.
+//Without an election, lots are relieved highest cost first after 2020 and oldest first before.
+func lotOrder(acct *store.InvAccount, date time.Time) (asc bool, hifo bool) {
+
+	basis := ""
+	if acct != nil {
+		basis = acct.CostBasis
+	}
+	switch basis {
+	case store.BasisFIFO:
+		return true, false
+	case store.BasisLIFO:
+		return false, false
+	case store.BasisHIFO:
+		return true, true
+	}
+	return true, date.Year() > 2020
+}
+
+func setAccountDefaults(acct *store.InvAccount) {
+
+	if len(acct.Type) == 0 {
+		acct.Type = store.AcctTaxable
+	}
+	if len(acct.TaxTreatment) == 0 {
+		acct.TaxTreatment = accountTaxTreatment(acct.Type)
+	}
+	if len(acct.Currency) == 0 {
+		acct.Currency = CurrencyUSD
+	}
+	acct.Currency = strings.ToUpper(acct.Currency)
+	if len(acct.Status) == 0 {
+		acct.Status = store.AcctOpen
+	}
+}
+
+//accountTaxTreatment returns the tax treatment of the account type
+func accountTaxTreatment(actType string) string {
+
+	switch actType {
+	case store.AcctTaxable, store.AcctWallet, store.AcctExchange:
+		return store.TaxTaxable
+	case store.AcctIRA, store.Acct401k:
+		return store.TaxDeferred
+	case store.AcctRoth, store.AcctHSA:
+		return store.TaxExempt
+	}
+	return ""
+}
@@ -0,0 +1,161 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+)
+
+const (
+	//AllocAssetClass defines the allocation by the asset class of the ticker
+	AllocAssetClass string = "assetClass"
+	//AllocSector defines the allocation by the sector of the ticker
+	AllocSector string = "sector"
+	//AllocIndustry defines the allocation by the industry of the ticker
+	AllocIndustry string = "industry"
+	//AllocAccountType defines the allocation by the type of the account
+	AllocAccountType string = "accountType"
+	//AllocAccount defines the allocation by account
+	AllocAccount string = "account"
+	//AllocGroup defines the allocation by group
+	AllocGroup string = "group"
+	//AllocCategory defines the allocation by category
+	AllocCategory string = "category"
+	//AllocSymbol defines the allocation by symbol
+	AllocSymbol string = "symbol"
+
+	//AllocUnclassified is the name of the slice of holdings without a value for the dimension
+	AllocUnclassified string = "Unclassified"
+)
+
+//allocItem holds the value of a holding and its name in each dimension
+type allocItem struct {
+	holding   *store.InvHolding
+	names     map[string]string
+	mktValue  float64
+	costValue float64
+}
+
+//InvestmentsAllocation returns the market value of the holdings by the first dimension
+//with the drill-down into the following dimensions and the symbols.
+func (fn *Finance) InvestmentsAllocation(ctx context.Context, group string, category string, by []string) (store.Allocations, error) {
+
+	var dims []string
+	for _, dim := range by {
+		dim = strings.TrimSpace(dim)
+		switch dim {
+		case "":
+			continue
+		case AllocAssetClass, AllocSector, AllocIndustry, AllocAccountType, AllocAccount, AllocGroup, AllocCategory, AllocSymbol:
+		default:
+			return nil, fmt.Errorf("Allocation dimension %s is not valid", dim)
+		}
+		if !utils.Contains(dims, dim) {
+			dims = append(dims, dim)
+		}
+	}
+	if len(dims) == 0 {
+		dims = []string{AllocAssetClass}
+	}
+	if !utils.Contains(dims, AllocSymbol) {
+		dims = append(dims, AllocSymbol)
+	}
+
+	hs, err := fn.InvestmentsHoldings(ctx, group, category, true, "")
+	if err != nil {
+		return nil, err
+	}
+
+	items, total := fn.allocItems(ctx, hs)
+	return allocate(items, dims, total, total), nil
+}
+
+//allocItems returns the open holdings with their names in each dimension and their total market value
+func (fn *Finance) allocItems(ctx context.Context, hs store.InvHoldings) ([]*allocItem, float64) {
+
+	var symbols []string
+	for _, h := range hs {
+		if symbol := tickerSymbol(h.Symbol); !utils.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	tm := make(map[string]*store.Ticker)
+	for _, t := range fn.MDB.GetTickers(ctx, symbols) {
+		tm[t.Symbol] = t
+	}
+	am := fn.investmentsAccountsMap(ctx)
+
+	var items []*allocItem
+	total := 0.0
+	for _, h := range hs {
+		if h.Qty <= 0 {
+			continue
+		}
+		item := &allocItem{holding: h, mktValue: h.MktValue, costValue: h.CostValue}
+		item.names = map[string]string{
+			AllocAccount:  h.Account,
+			AllocGroup:    h.Group,
+			AllocCategory: h.Category,
+			AllocSymbol:   h.Symbol,
+		}
+		if t := tm[tickerSymbol(h.Symbol)]; t != nil {
+			item.names[AllocAssetClass] = t.AssetClass()
+			item.names[AllocSector] = t.Sector
+			item.names[AllocIndustry] = t.Industry
+		}
+		if acct := am[h.Account]; acct != nil {
+			item.names[AllocAccountType] = acct.Type
+		}
+		items = append(items, item)
+		total += h.MktValue
+	}
+	return items, total
+}
+
+//allocate returns the allocations of the items by the first dimension, drilling down into the rest
+func allocate(items []*allocItem, dims []string, parent float64, total float64) store.Allocations {
+
+	allocs := store.Allocations{}
+	if len(dims) == 0 {
+		return allocs
+	}
+
+	dim := dims[0]
+	am := make(map[string]*store.Allocation)
+	im := make(map[string][]*allocItem)
+	for _, item := range items {
+		name := item.names[dim]
+		if len(name) == 0 {
+			name = AllocUnclassified
+		}
+		alloc := am[name]
+		if alloc == nil {
+			alloc = &store.Allocation{Dimension: dim, Name: name}
+			am[name] = alloc
+			allocs = append(allocs, alloc)
+		}
+		alloc.MktValue += item.mktValue
+		alloc.CostValue += item.costValue
+		im[name] = append(im[name], item)
+	}
+
+	for _, alloc := range allocs {
+		if total != 0 {
+			alloc.Percent = utils.ToFixed(alloc.MktValue*100/total, 2)
+		}
+		if parent != 0 {
+			alloc.Weight = utils.ToFixed(alloc.MktValue*100/parent, 2)
+		}
+		alloc.Allocations = allocate(im[alloc.Name], dims[1:], alloc.MktValue, total)
+		alloc.MktValue = utils.ToFixed(alloc.MktValue, 2)
+		alloc.CostValue = utils.ToFixed(alloc.CostValue, 2)
+	}
+	sort.SliceStable(allocs, func(i, j int) bool {
+		return allocs[i].MktValue > allocs[j].MktValue
+	})
+	return allocs
+}
@@ -0,0 +1,238 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/providers"
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+)
+
+const (
+	//AnomalyCategory defines a month with unusual spending in a category
+	AnomalyCategory string = "category"
+	//AnomalyMerchant defines a transaction unusual for the merchant
+	AnomalyMerchant string = "merchant"
+	//AnomalyNewMerchant defines a large transaction with a merchant not seen before
+	AnomalyNewMerchant string = "new merchant"
+	//AnomalyDuplicate defines a transaction charged again by the merchant
+	AnomalyDuplicate string = "duplicate"
+	//AnomalyThreshold is the default z-score above which spending is flagged
+	AnomalyThreshold float64 = 3
+)
+
+//minimum history needed before a baseline is trusted
+const (
+	anomalyMinMonths  = 3
+	anomalyMinCharges = 3
+	anomalyMinAll     = 20
+	anomalyPercentile = 99
+)
+
+type anomalyNotification struct {
+	Text      string          `json:"text"`
+	Anomalies store.Anomalies `json:"anomalies"`
+}
+
+//TransactionsAnomalies flags the months and transactions of the last days that are unusual compared with
+//the months of history before them. Spending is flagged when its z-score is above the threshold.
+//The anomalies are posted to the notification webhook when notify is set.
+func (fn *Finance) TransactionsAnomalies(ctx context.Context, months int, days int, threshold float64, notify bool) (store.Anomalies, error) {
+
+	if months <= 0 {
+		months = 12
+	}
+	if days <= 0 {
+		days = 30
+	}
+	if threshold <= 0 {
+		threshold = AnomalyThreshold
+	}
+	if notify && len(fn.NotifyURL) == 0 {
+		return nil, errors.New("Notification webhook is not configured")
+	}
+
+	now := time.Now()
+	recent := now.AddDate(0, 0, -days)
+	first := time.Date(recent.Year(), recent.Month(), 1, 0, 0, 0, 0, recent.Location()).AddDate(0, -months, 0)
+
+	aggs, err := fn.MDB.AggregateTransactions(ctx, "debit", false, &first, &now)
+	if err != nil {
+		return nil, err
+	}
+	anoms := categoryAnomalies(aggs, first, recent, months, threshold)
+
+	actvs := fn.MDB.TransactionsActivities(ctx, "debit", &first, &now)
+	anoms = append(anoms, merchantAnomalies(actvs, recent, threshold)...)
+
+	if notify && len(anoms) > 0 {
+		nt := anomalyNotification{Anomalies: anoms}
+		nt.Text = fmt.Sprintf("%d unusual transactions or months found in the last %d days", len(anoms), days)
+		if err := providers.PostNotification(fn.NotifyURL, nt); err != nil {
+			log.Printf("Anomalies notification error: %v", err)
+		}
+	}
+	return anoms, nil
+}
+
+//categoryAnomalies compares the spending of each category in the recent months with the monthly
+//spending of the baseline months, counting months without spending as zero
+func categoryAnomalies(aggs []store.TransactionAgg, first time.Time, recent time.Time, months int, threshold float64) store.Anomalies {
+
+	type monthKey struct {
+		year  int32
+		month int32
+	}
+	totals := make(map[string]map[monthKey]float64)
+	names := make(map[string][2]string)
+	for _, agg := range aggs {
+		if strings.Compare(agg.Group, store.GroupTransfer) == 0 || strings.Compare(agg.Group, store.GroupIncome) == 0 {
+			continue
+		}
+		key := agg.Group + ":" + agg.Category
+		if totals[key] == nil {
+			totals[key] = make(map[monthKey]float64)
+			names[key] = [2]string{agg.Group, agg.Category}
+		}
+		totals[key][monthKey{agg.Year, agg.Month}] += agg.Amount
+	}
+
+	var keys []string
+	for key := range totals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	start := time.Date(recent.Year(), recent.Month(), 1, 0, 0, 0, 0, recent.Location())
+	anoms := store.Anomalies{}
+	for _, key := range keys {
+
+		var base []float64
+		spent := 0
+		for x := 0; x < months; x++ {
+			month := first.AddDate(0, x, 0)
+			amount := totals[key][monthKey{int32(month.Year()), int32(month.Month())}]
+			if amount > 0 {
+				spent++
+			}
+			base = append(base, amount)
+		}
+		if spent < anomalyMinMonths {
+			continue
+		}
+		mean := utils.Mean(base)
+		std := anomalyStdDev(base, mean)
+
+		for month := start; !month.After(time.Now()); month = month.AddDate(0, 1, 0) {
+			mk := monthKey{int32(month.Year()), int32(month.Month())}
+			amount := totals[key][mk]
+			zscore := (amount - mean) / std
+			if zscore < threshold {
+				continue
+			}
+			anom := &store.Anomaly{Type: AnomalyCategory, Group: names[key][0], Category: names[key][1], Year: mk.year, Month: mk.month}
+			anom.Amount = utils.ToFixed(amount, 2)
+			anom.Mean = utils.ToFixed(mean, 2)
+			anom.StdDev = utils.ToFixed(std, 2)
+			anom.ZScore = utils.ToFixed(zscore, 2)
+			anom.Percentile = utils.ToFixed(utils.Percentile(base, amount), 2)
+			anom.Reason = fmt.Sprintf("Spending is %.1f standard deviations above the monthly average", zscore)
+			anoms = append(anoms, anom)
+		}
+	}
+	return anoms
+}
+
+//merchantAnomalies compares each recent transaction with the earlier transactions of the merchant.
+//Transactions with new merchants are flagged when larger than almost all earlier spending,
+//and repeated charges of the same amount within a few days are flagged as duplicates.
+func merchantAnomalies(actvs store.Activities, recent time.Time, threshold float64) store.Anomalies {
+
+	var all []float64
+	history := make(map[string][]float64)
+	last := make(map[string]store.Activities)
+	anoms := store.Anomalies{}
+
+	//activities are sorted by date so each transaction is compared with the ones before it
+	for _, actv := range actvs {
+		if isTransfer(actv) || strings.Compare(actv.Transfer, store.TransferMatched) == 0 ||
+			strings.Compare(actv.Transfer, store.TransferConfirmed) == 0 {
+			continue
+		}
+		merchant := utils.NormalizeMerchant(actv.Description)
+		if len(merchant) == 0 {
+			continue
+		}
+
+		if !actv.Date.Before(recent) {
+			if anom := merchantAnomaly(actv, merchant, history[merchant], all, last[merchant], threshold); anom != nil {
+				anoms = append(anoms, anom)
+			}
+			last[merchant] = append(last[merchant], actv)
+			continue
+		}
+		history[merchant] = append(history[merchant], actv.Amount)
+		all = append(all, actv.Amount)
+	}
+
+	sort.SliceStable(anoms, func(i, j int) bool {
+		return anoms[i].Activity.Date.After(*anoms[j].Activity.Date)
+	})
+	return anoms
+}
+
+func merchantAnomaly(actv *store.Activity, merchant string, history []float64, all []float64, prev store.Activities, threshold float64) *store.Anomaly {
+
+	anom := &store.Anomaly{Merchant: merchant, Group: actv.Group, Category: actv.Category, Activity: actv}
+	anom.Year = int32(actv.Date.Year())
+	anom.Month = int32(actv.Date.Month())
+	anom.Amount = actv.Amount
+
+	for _, p := range prev {
+		if math.Abs(p.Amount-actv.Amount) < 0.005 && daysBetween(*p.Date, *actv.Date) <= DuplicateDays {
+			anom.Type = AnomalyDuplicate
+			anom.Reason = fmt.Sprintf("Charged the same amount on %s", p.Date.Format("2006-01-02"))
+			return anom
+		}
+	}
+
+	if len(history) >= anomalyMinCharges {
+		mean := utils.Mean(history)
+		std := anomalyStdDev(history, mean)
+		zscore := (actv.Amount - mean) / std
+		if zscore < threshold {
+			return nil
+		}
+		anom.Type = AnomalyMerchant
+		anom.Mean = utils.ToFixed(mean, 2)
+		anom.StdDev = utils.ToFixed(std, 2)
+		anom.ZScore = utils.ToFixed(zscore, 2)
+		anom.Percentile = utils.ToFixed(utils.Percentile(history, actv.Amount), 2)
+		anom.Reason = fmt.Sprintf("Amount is %.1f standard deviations above the merchant average", zscore)
+		return anom
+	}
+
+	if len(history) == 0 && len(all) >= anomalyMinAll {
+		pct := utils.Percentile(all, actv.Amount)
+		if pct < anomalyPercentile {
+			return nil
+		}
+		anom.Type = AnomalyNewMerchant
+		anom.Percentile = utils.ToFixed(pct, 2)
+		anom.Reason = "First transaction with the merchant is larger than almost all earlier spending"
+		return anom
+	}
+	return nil
+}
+
+//anomalyStdDev returns the standard deviation with a floor so steady spending does not flag small changes
+func anomalyStdDev(values []float64, mean float64) float64 {
+	return math.Max(utils.StdDev(values), math.Max(mean*0.1, 1))
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	//LimitIRA defines the limit shared by the traditional and Roth IRA accounts
+	LimitIRA string = "IRA"
+	//Limit401k defines the limit of the employee deferrals to 401k plans
+	Limit401k string = "401k"
+	//LimitHSA defines the limit of self-only health savings accounts
+	LimitHSA string = "HSA"
+)
+
+//defaultLimits holds the published limits by year, used when the user has not configured a limit for the year
+var defaultLimits = map[int32]map[string]store.ContributionLimit{
+	2019: {LimitIRA: {Limit: 6000, CatchUp: 1000, CatchUpAge: 50}, Limit401k: {Limit: 19000, CatchUp: 6000, CatchUpAge: 50}, LimitHSA: {Limit: 3500, CatchUp: 1000, CatchUpAge: 55}},
+	2020: {LimitIRA: {Limit: 6000, CatchUp: 1000, CatchUpAge: 50}, Limit401k: {Limit: 19500, CatchUp: 6500, CatchUpAge: 50}, LimitHSA: {Limit: 3550, CatchUp: 1000, CatchUpAge: 55}},
+	2021: {LimitIRA: {Limit: 6000, CatchUp: 1000, CatchUpAge: 50}, Limit401k: {Limit: 19500, CatchUp: 6500, CatchUpAge: 50}, LimitHSA: {Limit: 3600, CatchUp: 1000, CatchUpAge: 55}},
+	2022: {LimitIRA: {Limit: 6000, CatchUp: 1000, CatchUpAge: 50}, Limit401k: {Limit: 20500, CatchUp: 6500, CatchUpAge: 50}, LimitHSA: {Limit: 3650, CatchUp: 1000, CatchUpAge: 55}},
+	2023: {LimitIRA: {Limit: 6500, CatchUp: 1000, CatchUpAge: 50}, Limit401k: {Limit: 22500, CatchUp: 7500, CatchUpAge: 50}, LimitHSA: {Limit: 3850, CatchUp: 1000, CatchUpAge: 55}},
+	2024: {LimitIRA: {Limit: 7000, CatchUp: 1000, CatchUpAge: 50}, Limit401k: {Limit: 23000, CatchUp: 7500, CatchUpAge: 50}, LimitHSA: {Limit: 4150, CatchUp: 1000, CatchUpAge: 55}},
+	2025: {LimitIRA: {Limit: 7000, CatchUp: 1000, CatchUpAge: 50}, Limit401k: {Limit: 23500, CatchUp: 7500, CatchUpAge: 50}, LimitHSA: {Limit: 4300, CatchUp: 1000, CatchUpAge: 55}},
+	2026: {LimitIRA: {Limit: 7500, CatchUp: 1100, CatchUpAge: 50}, Limit401k: {Limit: 24500, CatchUp: 8000, CatchUpAge: 50}, LimitHSA: {Limit: 4400, CatchUp: 1000, CatchUpAge: 55}},
+}
+
+//UserSettings returns the settings of the user
+func (fn *Finance) UserSettings(ctx context.Context) *store.UserSettings {
+	return fn.MDB.GetUserSettings(ctx)
+}
+
+//UserSettingsUpdate updates the settings of the user
+func (fn *Finance) UserSettingsUpdate(ctx context.Context, settings *store.UserSettings) error {
+	return fn.MDB.UserSettingsUpdate(ctx, settings)
+}
+
+//ContributionLimits returns the limits for the year, the configured limits replacing the published ones
+func (fn *Finance) ContributionLimits(ctx context.Context, year int32) store.ContributionLimits {
+
+	lm := make(map[string]*store.ContributionLimit)
+	for ltype, limit := range defaultLimits[year] {
+		limit := limit
+		limit.Year = year
+		limit.Type = ltype
+		lm[ltype] = &limit
+	}
+	for _, limit := range fn.MDB.ContributionLimits(ctx, year) {
+		lm[limit.Type] = limit
+	}
+
+	limits := store.ContributionLimits{}
+	for _, limit := range lm {
+		limits = append(limits, limit)
+	}
+	sort.SliceStable(limits, func(i, j int) bool {
+		return limits[i].Type < limits[j].Type
+	})
+	return limits
+}
+
+//ContributionLimitsUpdate validates and updates the configured contribution limits
+func (fn *Finance) ContributionLimitsUpdate(ctx context.Context, limits store.ContributionLimits) error {
+
+	for _, limit := range limits {
+		if limit.Year <= 0 {
+			return fmt.Errorf("Year is required for the %s limit", limit.Type)
+		}
+		switch limit.Type {
+		case LimitIRA, Limit401k, LimitHSA:
+		default:
+			return fmt.Errorf("Limit type %s is not valid", limit.Type)
+		}
+		if limit.Limit < 0 || limit.CatchUp < 0 {
+			return fmt.Errorf("%s limit for %d must not be negative", limit.Type, limit.Year)
+		}
+	}
+	return fn.MDB.ContributionLimitsUpdate(ctx, limits)
+}
+
+//ContributionLimitDelete deletes the configured contribution limit
+func (fn *Finance) ContributionLimitDelete(ctx context.Context, id primitive.ObjectID) error {
+	return fn.MDB.DeleteContributionLimit(ctx, id)
+}
+
+//Contributions returns the contributions for the tax year against the limits.
+//IRA and HSA contributions made before the deadline in the following year count for the year when tagged with
+//the tax year. 401k deferrals count for the year they are made.
+//Only one side of a contribution should be tagged, the transaction leaving the bank or the activity in the account.
+func (fn *Finance) Contributions(ctx context.Context, year int32) (store.Contributions, error) {
+
+	if year <= 0 {
+		year = int32(time.Now().Year())
+	}
+
+	settings := fn.MDB.GetUserSettings(ctx)
+	am := fn.investmentsAccountsMap(ctx)
+
+	cm := make(map[string]*store.Contribution)
+	cs := store.Contributions{}
+	for _, limit := range fn.ContributionLimits(ctx, year) {
+		deadline := contributionDeadline(limit.Type, year)
+		c := &store.Contribution{Year: year, Type: limit.Type, Limit: limit.Limit, Deadline: &deadline}
+		c.Accounts = []string{}
+		c.Warnings = []string{}
+		c.Activities = store.Activities{}
+		if catchUpEligible(settings, year, limit.CatchUpAge) {
+			c.CatchUp = limit.CatchUp
+		}
+		cm[limit.Type] = c
+		cs = append(cs, c)
+	}
+
+	ft := time.Date(int(year), time.January, 1, 0, 0, 0, 0, time.Now().Location())
+	et := time.Date(int(year)+1, time.December, 31, 24, 0, 0, 0, time.Now().Location())
+	for _, actv := range fn.MDB.ContributionActivities(ctx, &ft, &et) {
+
+		account := actv.Account
+		if len(actv.ToAccount) > 0 {
+			account = actv.ToAccount
+		}
+		acct := am[account]
+		if acct == nil {
+			continue
+		}
+		c := cm[contributionLimitType(acct.Type)]
+		if c == nil || contributionYear(actv, c.Type) != year {
+			continue
+		}
+
+		c.Contributed += contributionAmount(actv)
+		c.Activities = append(c.Activities, actv)
+		if !utils.Contains(c.Accounts, account) {
+			c.Accounts = append(c.Accounts, account)
+		}
+		if actv.Date.After(*c.Deadline) {
+			c.Warnings = append(c.Warnings, fmt.Sprintf("Contribution of %.2f on %s is after the %d deadline", actv.Amount, actv.Date.Format("2006-01-02"), year))
+		}
+	}
+
+	for _, c := range cs {
+		c.Contributed = utils.ToFixed(c.Contributed, 2)
+		limit := c.Limit + c.CatchUp
+		if c.Contributed > limit {
+			c.Excess = utils.ToFixed(c.Contributed-limit, 2)
+			c.Warnings = append(c.Warnings, fmt.Sprintf("Contributions exceed the %d limit by %.2f", year, c.Excess))
+		} else {
+			c.Remaining = utils.ToFixed(limit-c.Contributed, 2)
+		}
+	}
+
+	return cs, nil
+}
+
+//contributionYear returns the tax year of the contribution. 401k deferrals belong to the year they are made.
+func contributionYear(actv *store.Activity, limitType string) int32 {
+	if actv.TaxYear > 0 && strings.Compare(limitType, Limit401k) != 0 {
+		return actv.TaxYear
+	}
+	return int32(actv.Date.Year())
+}
+
+func contributionAmount(actv *store.Activity) float64 {
+	if actv.Amount != 0 {
+		return actv.Amount
+	}
+	return actv.Qty * actv.Price
+}
+
+//contributionDeadline returns the end of the last day to contribute to the limit for the tax year.
+//401k deferrals are made by the end of the year, IRA and HSA contributions by the tax filing deadline.
+func contributionDeadline(limitType string, year int32) time.Time {
+	if strings.Compare(limitType, Limit401k) == 0 {
+		return time.Date(int(year), time.December, 31, 24, 0, 0, 0, time.Now().Location())
+	}
+	return time.Date(int(year)+1, time.April, 15, 24, 0, 0, 0, time.Now().Location())
+}
+
+//contributionLimitType returns the limit the account type contributes to
+func contributionLimitType(actType string) string {
+
+	switch actType {
+	case store.AcctIRA, store.AcctRoth:
+		return LimitIRA
+	case store.Acct401k:
+		return Limit401k
+	case store.AcctHSA:
+		return LimitHSA
+	}
+	return ""
+}
+
+//catchUpEligible returns true if the user reaches the catch-up age by the end of the year
+func catchUpEligible(settings *store.UserSettings, year int32, age int) bool {
+	if settings == nil || settings.BirthDate == nil || age <= 0 {
+		return false
+	}
+	return settings.BirthDate.Year()+age <= int(year)
+}
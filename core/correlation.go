@@ -0,0 +1,162 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+)
+
+const (
+	//FreqDaily defines the returns between the daily closes
+	FreqDaily string = "daily"
+	//FreqWeekly defines the returns between the last closes of the weeks
+	FreqWeekly string = "weekly"
+)
+
+//minCorrelationReturns is the number of common returns needed to compute a correlation
+const minCorrelationReturns = 3
+
+//InvestmentsCorrelation returns the correlation matrix and the rolling correlations of the returns of the symbols
+//over the lookback period. The symbols default to the symbols of the holdings. Each pair is compared on the dates
+//both symbols have a close, so stocks and crypto are compared on the trading days.
+func (fn *Finance) InvestmentsCorrelation(ctx context.Context, symbols []string, lookback string, frequency string, window int) (*store.Correlation, error) {
+
+	if len(lookback) == 0 {
+		lookback = DefaultRiskPeriod
+	}
+	pdate := utils.DateForPeriod(lookback)
+	if pdate == nil {
+		return nil, fmt.Errorf("Lookback %s is not valid", lookback)
+	}
+	start := perfDate(*pdate)
+
+	if len(frequency) == 0 {
+		frequency = FreqDaily
+	}
+	switch frequency {
+	case FreqDaily:
+		if window == 0 {
+			window = 30
+		}
+	case FreqWeekly:
+		if window == 0 {
+			window = 12
+		}
+	default:
+		return nil, fmt.Errorf("Frequency %s is not valid", frequency)
+	}
+	if window < minCorrelationReturns {
+		return nil, fmt.Errorf("Window must be at least %d returns", minCorrelationReturns)
+	}
+
+	if len(symbols) == 0 {
+		hs, err := fn.InvestmentsHoldings(ctx, "", "", false, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hs {
+			if h.Qty > 0 {
+				symbols = append(symbols, tickerSymbol(h.Symbol))
+			}
+		}
+	}
+	var syms []string
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if len(symbol) > 0 && !utils.Contains(syms, symbol) {
+			syms = append(syms, symbol)
+		}
+	}
+	sort.Strings(syms)
+
+	closes := make([]map[time.Time]float64, len(syms))
+	for x, symbol := range syms {
+		closes[x] = fn.correlationCloses(ctx, symbol, start, frequency)
+	}
+
+	corr := &store.Correlation{Symbols: syms, Lookback: lookback, Frequency: frequency, Window: window}
+	corr.Matrix = make([][]float64, len(syms))
+	corr.Observations = make([][]int, len(syms))
+	corr.Rolling = []*store.RollingCorrelation{}
+	for i := range syms {
+		corr.Matrix[i] = make([]float64, len(syms))
+		corr.Observations[i] = make([]int, len(syms))
+	}
+
+	for i := range syms {
+		corr.Matrix[i][i] = 1
+		for j := i + 1; j < len(syms); j++ {
+			dates, ri, rj := pairReturns(closes[i], closes[j])
+			corr.Observations[i][j] = len(ri)
+			corr.Observations[j][i] = len(ri)
+			if len(ri) < minCorrelationReturns {
+				continue
+			}
+			value := utils.ToFixed(utils.Correlation(ri, rj), 4)
+			corr.Matrix[i][j] = value
+			corr.Matrix[j][i] = value
+
+			rc := &store.RollingCorrelation{Symbol1: syms[i], Symbol2: syms[j]}
+			rc.Points = []*store.CorrelationPoint{}
+			for e := window; e <= len(ri); e++ {
+				date := dates[e-1]
+				cp := &store.CorrelationPoint{Date: &date}
+				cp.Value = utils.ToFixed(utils.Correlation(ri[e-window:e], rj[e-window:e]), 4)
+				rc.Points = append(rc.Points, cp)
+			}
+			corr.Rolling = append(corr.Rolling, rc)
+		}
+	}
+	return corr, nil
+}
+
+//correlationCloses returns the closes of the symbol from the start date by day, or by the end of the week for weekly returns
+func (fn *Finance) correlationCloses(ctx context.Context, symbol string, start time.Time, frequency string) map[time.Time]float64 {
+
+	ths := fn.MDB.GetTickerHistory(ctx, symbol)
+	sort.SliceStable(ths, func(i, j int) bool {
+		return ths[i].Date.Before(ths[j].Date)
+	})
+
+	closes := make(map[time.Time]float64)
+	for _, th := range ths {
+		date := perfDate(th.Date)
+		if th.Close <= 0 || date.Before(start) {
+			continue
+		}
+		if strings.Compare(frequency, FreqWeekly) == 0 {
+			date = date.AddDate(0, 0, int(time.Saturday-date.Weekday()))
+		}
+		closes[date] = th.Close
+	}
+	return closes
+}
+
+//pairReturns returns the returns of both symbols between their consecutive common dates
+func pairReturns(c1 map[time.Time]float64, c2 map[time.Time]float64) ([]time.Time, []float64, []float64) {
+
+	var common []time.Time
+	for date := range c1 {
+		if _, ok := c2[date]; ok {
+			common = append(common, date)
+		}
+	}
+	sort.Slice(common, func(i, j int) bool {
+		return common[i].Before(common[j])
+	})
+
+	var dates []time.Time
+	var r1, r2 []float64
+	for x := 1; x < len(common); x++ {
+		prev, date := common[x-1], common[x]
+		dates = append(dates, date)
+		r1 = append(r1, c1[date]/c1[prev]-1)
+		r2 = append(r2, c2[date]/c2[prev]-1)
+	}
+	return dates, r1, r2
+}
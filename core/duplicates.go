@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//DuplicateDays is the default number of days either side of the date searched for duplicates
+const DuplicateDays = 3
+
+//ActivitiesDuplicates returns the suspected duplicate pairs with the status
+func (fn *Finance) ActivitiesDuplicates(ctx context.Context, status string) store.ActvDuplicates {
+	dups := fn.MDB.ActvDuplicates(ctx, status)
+	if dups == nil {
+		dups = store.ActvDuplicates{}
+	}
+	return dups
+}
+
+//ActivitiesDuplicateResolve records the review decision for the duplicate pair.
+//Merge keeps the original activity and deletes the imported copy, ignore keeps both.
+func (fn *Finance) ActivitiesDuplicateResolve(ctx context.Context, id primitive.ObjectID, decision string) error {
+
+	dup := fn.MDB.GetActvDuplicate(ctx, id)
+	if dup == nil {
+		return errors.New("Duplicate not found")
+	}
+
+	switch strings.ToLower(decision) {
+	case "merge":
+
+		orig := fn.MDB.GetActivity(ctx, dup.ActvID)
+		imp := fn.MDB.GetActivity(ctx, dup.DupID)
+		if orig != nil && imp != nil {
+			if len(orig.Group) == 0 {
+				orig.Group = imp.Group
+			}
+			if len(orig.Category) == 0 {
+				orig.Category = imp.Category
+			}
+			err := fn.MDB.ActivitiesUpdate(ctx, store.Activities{orig})
+			if err != nil {
+				return err
+			}
+		}
+		if orig != nil || imp == nil {
+			err := fn.MDB.DeleteActivity(ctx, dup.DupID)
+			if err != nil {
+				return err
+			}
+		}
+		return fn.MDB.ActvDuplicateUpdateStatus(ctx, id, store.DupMerged)
+
+	case "ignore":
+		return fn.MDB.ActvDuplicateUpdateStatus(ctx, id, store.DupIgnored)
+	}
+
+	return errors.New("Decision must be merge or ignore")
+}
+
+//flagDuplicates compares imported transactions with the stored ones and queues suspected duplicates for review.
+//Transactions merged on an earlier review are dropped from the import.
+func (fn *Finance) flagDuplicates(ctx context.Context, actvs store.Activities, days int) store.Activities {
+
+	if len(actvs) == 0 || days < 0 {
+		return actvs
+	}
+
+	ft := *actvs[0].Date
+	et := *actvs[0].Date
+	for _, actv := range actvs {
+		if actv.Date.Before(ft) {
+			ft = *actv.Date
+		}
+		if actv.Date.After(et) {
+			et = *actv.Date
+		}
+	}
+	ft = ft.AddDate(0, 0, -days)
+	et = et.AddDate(0, 0, days+1)
+
+	im := make(map[primitive.ObjectID]bool)
+	for _, actv := range actvs {
+		if actv.ID.IsZero() {
+			actv.ID = primitive.NewObjectID()
+		}
+		im[actv.ID] = true
+	}
+
+	am := make(map[string]store.Activities)
+	for _, actv := range fn.MDB.TransactionsActivities(ctx, "", &ft, &et) {
+		if im[actv.ID] {
+			continue
+		}
+		am[actv.Account] = append(am[actv.Account], actv)
+	}
+
+	//match each imported activity with the closest stored activity
+	used := make(map[primitive.ObjectID]bool)
+	var dups store.ActvDuplicates
+	var keys []string
+	for _, actv := range actvs {
+
+		var match *store.Activity
+		for _, cand := range am[actv.Account] {
+			if used[cand.ID] || !isDuplicate(cand, actv, days) {
+				continue
+			}
+			if match == nil || daysBetween(*cand.Date, *actv.Date) < daysBetween(*match.Date, *actv.Date) {
+				match = cand
+			}
+		}
+		if match == nil {
+			continue
+		}
+		used[match.ID] = true
+
+		dup := &store.ActvDuplicate{}
+		dup.Key = store.DuplicateKey(match, actv)
+		dup.ActvID = match.ID
+		dup.DupID = actv.ID
+		dup.Activity = match
+		dup.Duplicate = actv
+		dups = append(dups, dup)
+		keys = append(keys, dup.Key)
+	}
+
+	if len(dups) == 0 {
+		return actvs
+	}
+
+	dm := make(map[string]*store.ActvDuplicate)
+	for _, dup := range fn.MDB.ActvDuplicatesByKeys(ctx, keys) {
+		dm[dup.Key] = dup
+	}
+
+	drop := make(map[primitive.ObjectID]bool)
+	var udups store.ActvDuplicates
+	for _, dup := range dups {
+		prev := dm[dup.Key]
+		if prev != nil && strings.Compare(prev.Status, store.DupMerged) == 0 {
+			drop[dup.DupID] = true
+			continue
+		}
+		if prev != nil && strings.Compare(prev.Status, store.DupIgnored) == 0 {
+			continue
+		}
+		udups = append(udups, dup)
+	}
+
+	err := fn.MDB.ActvDuplicatesUpdate(ctx, udups)
+	if err != nil {
+		log.Printf("Duplicates update error: %v", err)
+	}
+	log.Printf("Duplicates - flagged: %d merged: %d", len(udups), len(drop))
+
+	if len(drop) == 0 {
+		return actvs
+	}
+	var ractvs store.Activities
+	for _, actv := range actvs {
+		if !drop[actv.ID] {
+			ractvs = append(ractvs, actv)
+		}
+	}
+	return ractvs
+}
+
+//isDuplicate returns true if the activities have the same amount, close dates and similar descriptions
+func isDuplicate(actv *store.Activity, dup *store.Activity, days int) bool {
+
+	if strings.Compare(actv.Dbcr, dup.Dbcr) != 0 {
+		return false
+	}
+	if math.Abs(actv.Amount-dup.Amount) >= 0.005 {
+		return false
+	}
+	if daysBetween(*actv.Date, *dup.Date) > float64(days) {
+		return false
+	}
+	return descriptionsMatch(actv.Description, dup.Description)
+}
+
+//descriptionsMatch compares the normalized descriptions by containment or shared words
+func descriptionsMatch(desc1 string, desc2 string) bool {
+
+	n1 := utils.NormalizeMerchant(desc1)
+	n2 := utils.NormalizeMerchant(desc2)
+	if len(n1) == 0 || len(n2) == 0 {
+		return len(n1) == len(n2)
+	}
+	if strings.Contains(n1, n2) || strings.Contains(n2, n1) {
+		return true
+	}
+
+	wm := make(map[string]bool)
+	for _, w := range strings.Fields(n1) {
+		wm[w] = true
+	}
+	common := 0
+	words := strings.Fields(n2)
+	for _, w := range words {
+		if wm[w] {
+			common++
+			delete(wm, w)
+		}
+	}
+	total := len(wm) + len(words)
+	return total > 0 && float64(common)/float64(total) >= 0.5
+}
@@ -2,17 +2,21 @@ package core
 
 import (
 	"context"
+	"log"
 	"sort"
 	"strings"
 	"time"
 
+	"github.com/rkapps/go_finance/providers"
 	"github.com/rkapps/go_finance/store"
 	"github.com/shopspring/decimal"
 )
 
 //Finance defines the main struct
 type Finance struct {
-	MDB *store.MongoDB
+	MDB       *store.MongoDB
+	NotifyURL string
+	Providers *providers.Registry
 }
 
 //NewFinance creates new Finance
@@ -22,13 +26,32 @@ func NewFinance(mongoConnStr string) (*Finance, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &Finance{MDB: mdb}, nil
+	reg, err := providers.NewRegistry("")
+	if err != nil {
+		return nil, err
+	}
+	return &Finance{MDB: mdb, Providers: reg}, nil
 }
 
-func (fn *Finance) ActivitiesImport(ctx context.Context, actyType string, group string, category string, fromDate *time.Time, toDate *time.Time, actvs store.Activities) error {
+//ActivitiesImport replaces the activities for the date range, creating the investment lots.
+//Transactions matching stored ones within dupDays are queued for duplicate review.
+func (fn *Finance) ActivitiesImport(ctx context.Context, actyType string, group string, category string, fromDate *time.Time, toDate *time.Time, dupDays int, actvs store.Activities) error {
 
 	// fn.MDB.DropActivitiesCollection(ctx)
 
+	for _, actv := range actvs {
+		err := actv.ValidateSplits()
+		if err != nil {
+			return err
+		}
+	}
+
+	//transfers paired with the deleted transactions are matched again with the imported ones
+	var released store.Activities
+	if strings.Compare("Transaction", actyType) == 0 {
+		released = fn.releaseTransfers(ctx, group, category, fromDate, toDate)
+	}
+
 	fn.MDB.DeleteActivities(ctx, actyType, group, category, fromDate, toDate)
 	if strings.Compare("Investment", actyType) == 0 {
 		fn.MDB.DeleteInvlots(ctx, group, category, fromDate, toDate)
@@ -37,6 +60,7 @@ func (fn *Finance) ActivitiesImport(ctx context.Context, actyType string, group
 	// return nil
 
 	var uptxns store.Activities
+	accts := fn.investmentsAccountsMap(ctx)
 
 	//Sort by date ascending
 	sort.SliceStable(actvs, func(i, j int) bool {
@@ -47,15 +71,15 @@ func (fn *Finance) ActivitiesImport(ctx context.Context, actyType string, group
 
 		var upactvs store.Activities
 		var ulots store.InvLots
-		var qty = actv.Qty
-		var hifo bool = false
-		var asc = true
-
-		// //LIFO logic
-		if actv.Date.Year() > 2020 {
-			// asc = false
-			hifo = true
+
+		//lots are relieved in the order of the cost basis elected for the account
+		asc, hifo := lotOrder(accts[actv.Account], *actv.Date)
+
+		//prices are in the currency of the account unless the activity has its own
+		if acct := accts[actv.Account]; len(actv.Currency) == 0 && acct != nil {
+			actv.Currency = acct.Currency
 		}
+		actv.Currency = strings.ToUpper(actv.Currency)
 
 		// var update = true
 		// fmt.Printf("date: %v\n", actv.ActyType)
@@ -68,23 +92,7 @@ func (fn *Finance) ActivitiesImport(ctx context.Context, actyType string, group
 				strings.Compare("Rewards", actv.TxnType) == 0 {
 				// actv.OrigQty = actv.Qty
 
-				lot := &store.InvLot{}
-				lot.ActvID = actv.ID
-				lot.Group = actv.Group
-				lot.Category = actv.Category
-				lot.Account = actv.Account
-				lot.Symbol = actv.Symbol
-				lot.Date = actv.Date
-				lot.TxnType = actv.TxnType
-				lot.TxnDate = actv.Date
-				lot.Status = "O"
-				lot.OrigQty = actv.Qty
-				lot.Qty = actv.Qty
-				lot.Cost = actv.Price
-				// lot.CostValue = lot.Qty * lot.Cost
-				lot.Fee = actv.Fee
-
-				ulots = append(ulots, lot)
+				ulots = append(ulots, newLot(actv))
 
 			} else if strings.Compare("Send", actv.TxnType) == 0 ||
 				strings.Compare("Sale", actv.TxnType) == 0 {
@@ -95,108 +103,161 @@ func (fn *Finance) ActivitiesImport(ctx context.Context, actyType string, group
 					continue
 				}
 
-				// log.Printf("Send To: %s\n", actv.ToMerchant)
-				// log.Printf("Account: %s TxnType: %s  Qty: %f \n", actv.Account, actv.TxnType, qty)
+				ulots = append(ulots, relieveLots(actv, lots)...)
 
-				for _, lot := range lots {
+			}
 
-					if lot.Status == "C" || lot.Qty == 0 {
-						continue
-					}
+			// log.Printf("Activities county: %d\n", len(upactvs))
+			fn.MDB.InvLotsUpdate(ctx, ulots)
 
-					// log.Printf("    Orig--- Date: %v Qty: %v Price: %f \n", lot.Date, lot.Qty, lot.Cost)
-					lqty := qty
+		} else if strings.Compare("Transaction", actv.ActyType) == 0 {
+			uptxns = append(uptxns, actv)
+		}
 
-					if qty > lot.Qty {
-						lqty = lot.Qty
-					}
+	}
 
-					dqty := decimal.NewFromFloat(qty)
-					sqty := decimal.NewFromFloat(lqty)
+	if len(uptxns) > 0 {
+		uptxns = fn.flagDuplicates(ctx, uptxns, dupDays)
+		fn.MDB.ActivitiesUpdate(ctx, uptxns)
+	}
 
-					qty, _ = dqty.Sub(sqty).Float64()
-					// log.Printf("    Lot Qty: %f Remaining Qty: %f", lqty, qty)
+	if mtxns := append(uptxns, released...); len(mtxns) > 0 {
+		ft, et := *mtxns[0].Date, *mtxns[0].Date
+		for _, txn := range mtxns {
+			if txn.Date.Before(ft) {
+				ft = *txn.Date
+			}
+			if txn.Date.After(et) {
+				et = *txn.Date
+			}
+		}
+		_, err := fn.TransactionsMatchTransfers(ctx, ft, et, TransferDays)
+		if err != nil {
+			log.Printf("Match transfers error: %v", err)
+		}
+	}
 
-					lot.Qty = lot.Qty - lqty
+	return nil
 
-					slot := &store.InvLot{}
-					slot.ActvID = lot.ActvID
-					slot.Group = lot.Group
-					slot.Category = lot.Category
-					slot.Account = lot.Account
-					slot.Symbol = lot.Symbol
-					slot.Date = lot.Date
-					slot.TxnType = lot.TxnType
-					slot.TxnDate = actv.Date
-					slot.OrigQty = lot.OrigQty
-					slot.Qty = lqty
-					slot.Cost = lot.Cost
-					// slot.CostValue = slot.Qty * slot.Cost
-					slot.Status = "O"
-					slot.Fee = actv.Fee
+}
 
-					if strings.Compare("Sale", actv.TxnType) == 0 {
+//newLot returns the open lot for the quantity bought or rewarded by the activity
+func newLot(actv *store.Activity) *store.InvLot {
+
+	lot := &store.InvLot{}
+	lot.ActvID = actv.ID
+	lot.Group = actv.Group
+	lot.Category = actv.Category
+	lot.Account = actv.Account
+	lot.Symbol = actv.Symbol
+	lot.Date = actv.Date
+	lot.TxnType = actv.TxnType
+	lot.TxnDate = actv.Date
+	lot.Status = "O"
+	lot.OrigQty = actv.Qty
+	lot.Qty = actv.Qty
+	lot.Cost = actv.Price
+	lot.Currency = actv.Currency
+	// lot.CostValue = lot.Qty * lot.Cost
+	lot.Fee = actv.Fee
+	return lot
+}
 
-						if lot.Qty == 0 {
+//relieveLots relieves the quantity sold or sent by the activity from the open lots in order.
+//It returns the lots relieved and the slots created for the quantity sold or sent.
+func relieveLots(actv *store.Activity, lots store.InvLots) store.InvLots {
 
-							lot.Status = "C"
-							lot.TxnDate = actv.Date
-							lot.SaleDate = actv.Date
-							lot.SaleQty = lqty
-							lot.SalePrice = actv.Price
+	var ulots store.InvLots
+	var qty = actv.Qty
 
-						} else {
-							slot.Status = "C"
-							slot.Qty = 0
-							slot.SaleDate = actv.Date
-							slot.SaleQty = lqty
-							slot.SalePrice = actv.Price
-							ulots = append(ulots, slot)
-						}
+	// log.Printf("Send To: %s\n", actv.ToMerchant)
+	// log.Printf("Account: %s TxnType: %s  Qty: %f \n", actv.Account, actv.TxnType, qty)
 
-					} else {
+	for _, lot := range lots {
 
-						lot.SendQty = lot.SendQty + lqty
-						lot.SendDate = actv.Date
-						lot.TxnDate = actv.Date
+		if lot.Status == "C" || lot.Qty == 0 {
+			continue
+		}
 
-						if lot.Qty == 0 {
+		// log.Printf("    Orig--- Date: %v Qty: %v Price: %f \n", lot.Date, lot.Qty, lot.Cost)
+		lqty := qty
 
-							lot.Status = "C"
-							// lot.Account = actv.ToAccount
-						}
+		if qty > lot.Qty {
+			lqty = lot.Qty
+		}
 
-						slot.Account = actv.ToAccount
-						slot.OrigQty = slot.Qty
-						// slot.SendDate = actv.Date
-						// slot.SendQty = lqty
-						slot.OrigAccount = lot.OrigAccount + ":" + lot.Account
-						ulots = append(ulots, slot)
-					}
+		dqty := decimal.NewFromFloat(qty)
+		sqty := decimal.NewFromFloat(lqty)
+
+		qty, _ = dqty.Sub(sqty).Float64()
+		// log.Printf("    Lot Qty: %f Remaining Qty: %f", lqty, qty)
+
+		lot.Qty = lot.Qty - lqty
+
+		slot := &store.InvLot{}
+		slot.ActvID = lot.ActvID
+		slot.Group = lot.Group
+		slot.Category = lot.Category
+		slot.Account = lot.Account
+		slot.Symbol = lot.Symbol
+		slot.Date = lot.Date
+		slot.TxnType = lot.TxnType
+		slot.TxnDate = actv.Date
+		slot.OrigQty = lot.OrigQty
+		slot.Qty = lqty
+		slot.Cost = lot.Cost
+		slot.Currency = lot.Currency
+		slot.RecvDate = lot.RecvDate
+		// slot.CostValue = slot.Qty * slot.Cost
+		slot.Status = "O"
+		slot.Fee = actv.Fee
+
+		if strings.Compare("Sale", actv.TxnType) == 0 {
+
+			if lot.Qty == 0 {
+
+				lot.Status = "C"
+				lot.TxnDate = actv.Date
+				lot.SaleDate = actv.Date
+				lot.SaleQty = lqty
+				lot.SalePrice = actv.Price
+
+			} else {
+				slot.Status = "C"
+				slot.Qty = 0
+				slot.SaleDate = actv.Date
+				slot.SaleQty = lqty
+				slot.SalePrice = actv.Price
+				ulots = append(ulots, slot)
+			}
 
-					ulots = append(ulots, lot)
+		} else {
 
-					if qty <= 0 {
-						break
-					}
+			lot.SendQty = lot.SendQty + lqty
+			lot.SendDate = actv.Date
+			lot.TxnDate = actv.Date
 
-				}
+			if lot.Qty == 0 {
 
+				lot.Status = "C"
+				// lot.Account = actv.ToAccount
 			}
 
-			// log.Printf("Activities county: %d\n", len(upactvs))
-			fn.MDB.InvLotsUpdate(ctx, ulots)
-
-		} else if strings.Compare("Transaction", actv.ActyType) == 0 {
-			uptxns = append(uptxns, actv)
+			slot.Account = actv.ToAccount
+			slot.RecvDate = actv.Date
+			slot.OrigQty = slot.Qty
+			// slot.SendDate = actv.Date
+			// slot.SendQty = lqty
+			slot.OrigAccount = lot.OrigAccount + ":" + lot.Account
+			ulots = append(ulots, slot)
 		}
 
-	}
-
-	if len(uptxns) > 0 {
-		fn.MDB.ActivitiesUpdate(ctx, uptxns)
-	}
+		ulots = append(ulots, lot)
 
-	return nil
+		if qty <= 0 {
+			break
+		}
 
+	}
+	return ulots
 }
@@ -0,0 +1,293 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+)
+
+const (
+	//ForecastRecurring defines the source of items projected from recurring transactions
+	ForecastRecurring string = "recurring"
+	//ForecastBudget defines the source of items projected from budgets
+	ForecastBudget string = "budget"
+	//ForecastScheduled defines the source of scheduled items
+	ForecastScheduled string = "scheduled"
+)
+
+type forecastItem struct {
+	date        time.Time
+	account     string
+	description string
+	source      string
+	amount      float64
+}
+
+//Budgets returns the budgets
+func (fn *Finance) Budgets(ctx context.Context) store.Budgets {
+	budgets := fn.MDB.Budgets(ctx)
+	if budgets == nil {
+		budgets = store.Budgets{}
+	}
+	return budgets
+}
+
+//ScheduledItems returns the scheduled items from today
+func (fn *Finance) ScheduledItems(ctx context.Context) store.ScheduledItems {
+	today := time.Now().Truncate(24 * time.Hour)
+	items := fn.MDB.ScheduledItems(ctx, &today)
+	if items == nil {
+		items = store.ScheduledItems{}
+	}
+	return items
+}
+
+//Forecast projects the balances of the bank accounts for the months from recurring transactions, budgets and scheduled items.
+//Points where an account falls below the threshold are returned as alerts.
+func (fn *Finance) Forecast(ctx context.Context, months int, threshold float64) (store.Forecasts, error) {
+
+	if months <= 0 {
+		months = 6
+	}
+	if months > 12 {
+		months = 12
+	}
+
+	now := time.Now()
+	horizon := now.AddDate(0, months, 0)
+
+	fm := fn.forecastStart(ctx, now)
+	fcs := store.Forecasts{}
+	if len(fm) == 0 {
+		return fcs, nil
+	}
+
+	var accounts []string
+	for account, fc := range fm {
+		accounts = append(accounts, account)
+		fcs = append(fcs, fc)
+	}
+	sort.Strings(accounts)
+	sort.SliceStable(fcs, func(i, j int) bool {
+		return fcs[i].Account < fcs[j].Account
+	})
+
+	items, recurring := fn.forecastRecurring(ctx, now, horizon)
+	items = append(items, fn.forecastBudgets(ctx, now, horizon, recurring, accounts)...)
+	for _, si := range fn.MDB.ScheduledItems(ctx, &now) {
+		if si.Date.After(horizon) {
+			continue
+		}
+		item := forecastItem{date: *si.Date, account: si.Account, description: si.Description, source: ForecastScheduled, amount: -si.Amount}
+		if strings.Compare(si.Dbcr, "credit") == 0 {
+			item.amount = si.Amount
+		}
+		items = append(items, item)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].date.Before(items[j].date)
+	})
+
+	for _, item := range items {
+		fc := fm[item.account]
+		if fc == nil {
+			continue
+		}
+		date := item.date
+		fc.EndBalance = utils.ToFixed(fc.EndBalance+item.amount, 2)
+		point := &store.ForecastPoint{Date: &date, Description: item.description, Source: item.source, Amount: utils.ToFixed(item.amount, 2), Balance: fc.EndBalance}
+		fc.Points = append(fc.Points, point)
+
+		if fc.EndBalance < fc.LowBalance {
+			fc.LowBalance = fc.EndBalance
+			fc.LowDate = &date
+		}
+		if fc.EndBalance < threshold {
+			fc.Alerts = append(fc.Alerts, point)
+		}
+	}
+
+	return fcs, nil
+}
+
+//forecastStart returns the balance of the bank accounts today, the latest balance adjusted by the transactions since
+func (fn *Finance) forecastStart(ctx context.Context, now time.Time) map[string]*store.Forecast {
+
+	lm := make(map[string]*store.Balance)
+	for _, bal := range fn.MDB.Balances(ctx, "", &now) {
+		lm[bal.Account] = bal
+	}
+
+	fm := make(map[string]*store.Forecast)
+	var ft *time.Time
+	for account, bal := range lm {
+		if strings.Compare(bal.Kind, store.KindBank) != 0 {
+			continue
+		}
+		fc := &store.Forecast{}
+		fc.Account = account
+		fc.StartDate = &now
+		fc.StartBalance = bal.Value
+		fc.Points = []*store.ForecastPoint{}
+		fc.Alerts = []*store.ForecastPoint{}
+		fm[account] = fc
+		if ft == nil || bal.Date.Before(*ft) {
+			ft = bal.Date
+		}
+	}
+	if len(fm) == 0 {
+		return fm
+	}
+
+	for _, actv := range fn.MDB.TransactionsActivities(ctx, "", ft, &now) {
+		fc := fm[actv.Account]
+		if fc == nil || !actv.Date.After(*lm[actv.Account].Date) {
+			continue
+		}
+		if strings.Compare(actv.Dbcr, "credit") == 0 {
+			fc.StartBalance += actv.Amount
+		} else {
+			fc.StartBalance -= actv.Amount
+		}
+	}
+
+	for _, fc := range fm {
+		fc.StartBalance = utils.ToFixed(fc.StartBalance, 2)
+		fc.EndBalance = fc.StartBalance
+		fc.LowBalance = fc.StartBalance
+		fc.LowDate = &now
+	}
+	return fm
+}
+
+//forecastRecurring projects the recurring income and expenses, returning the monthly amount by group and category
+func (fn *Finance) forecastRecurring(ctx context.Context, now time.Time, horizon time.Time) ([]forecastItem, map[string]float64) {
+
+	var items []forecastItem
+	recurring := make(map[string]float64)
+
+	ft := now.AddDate(-2, 0, 0)
+	for _, dbcr := range []string{"debit", "credit"} {
+
+		actvs := fn.MDB.TransactionsActivities(ctx, dbcr, &ft, &now)
+		for _, sub := range detectSubscriptions(actvs, now, false) {
+
+			amount := -sub.Amount
+			if strings.Compare(dbcr, "credit") == 0 {
+				amount = sub.Amount
+			}
+
+			for date := *sub.NextDate; !date.After(horizon); date = nextCadenceDate(date, sub.Cadence) {
+				if date.Before(now) {
+					continue
+				}
+				items = append(items, forecastItem{date: date, account: sub.Account, description: sub.Merchant, source: ForecastRecurring, amount: amount})
+			}
+
+			if strings.Compare(dbcr, "debit") == 0 {
+				recurring[sub.Group+":"+sub.Category] += sub.Amount * cadencesPerMonth(sub.Cadence)
+			}
+		}
+	}
+	return items, recurring
+}
+
+//forecastBudgets spreads the budget not covered by recurring expenses to the middle of each month. Budgets without
+//an account are charged to the bank accounts in proportion to the spending of the category from each account over
+//the last year, or evenly when the category has no spending.
+func (fn *Finance) forecastBudgets(ctx context.Context, now time.Time, horizon time.Time, recurring map[string]float64, accounts []string) []forecastItem {
+
+	ft := now.AddDate(-1, 0, 0)
+	spent := make(map[string]map[string]float64)
+	for _, actv := range fn.MDB.TransactionsActivities(ctx, "debit", &ft, &now) {
+		if !utils.Contains(accounts, actv.Account) {
+			continue
+		}
+		key := actv.Group + ":" + actv.Category
+		if spent[key] == nil {
+			spent[key] = make(map[string]float64)
+		}
+		spent[key][actv.Account] += actv.Amount
+	}
+
+	var items []forecastItem
+	for _, budget := range fn.MDB.Budgets(ctx) {
+
+		key := budget.Group + ":" + budget.Category
+		amount := budget.Amount - recurring[key]
+		if amount <= 0 {
+			continue
+		}
+		shares := budgetShares(budget.Account, spent[key], accounts)
+
+		month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+		for ; !month.After(horizon); month = month.AddDate(0, 1, 0) {
+
+			date := month.AddDate(0, 0, 14)
+			mamount := amount
+			if month.Year() == now.Year() && month.Month() == now.Month() {
+				//only the rest of the current month is left to spend
+				end := month.AddDate(0, 1, 0)
+				mamount = amount * end.Sub(now).Hours() / end.Sub(month).Hours()
+				if date.Before(now) {
+					date = now
+				}
+			}
+			if date.After(horizon) {
+				break
+			}
+			for _, account := range accounts {
+				if share := shares[account]; share > 0 {
+					items = append(items, forecastItem{date: date, account: account, description: budget.Group + " - " + budget.Category, source: ForecastBudget, amount: -mamount * share})
+				}
+			}
+		}
+	}
+	return items
+}
+
+//budgetShares returns the share of the budget charged to each account: all of it to the account of the budget when set,
+//otherwise in proportion to the spending by account or evenly without spending
+func budgetShares(account string, spent map[string]float64, accounts []string) map[string]float64 {
+
+	shares := make(map[string]float64)
+	if len(account) > 0 {
+		shares[account] = 1
+		return shares
+	}
+	var total float64
+	for _, amount := range spent {
+		if amount > 0 {
+			total += amount
+		}
+	}
+	for _, acct := range accounts {
+		if total > 0 {
+			if spent[acct] > 0 {
+				shares[acct] = spent[acct] / total
+			}
+		} else {
+			shares[acct] = 1 / float64(len(accounts))
+		}
+	}
+	return shares
+}
+
+func cadencesPerMonth(name string) float64 {
+	switch name {
+	case CadenceWeekly:
+		return 52.0 / 12
+	case CadenceBiweekly:
+		return 26.0 / 12
+	case CadenceMonthly:
+		return 1
+	case CadenceAnnual:
+		return 1.0 / 12
+	}
+	return 0
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/providers"
+	"github.com/rkapps/go_finance/store"
+)
+
+//fxHistoryStart is the first day of the rates loaded for a currency without rates
+var fxHistoryStart = time.Date(2010, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+//fxRates holds the daily rates of the currencies loaded on first use
+type fxRates struct {
+	fn    *Finance
+	ctx   context.Context
+	rates map[string][]pricePoint
+}
+
+//UpdateFXRates loads the daily rates of the currencies in use since their last stored rate
+func (fn *Finance) UpdateFXRates(ctx context.Context) {
+
+	et := time.Now()
+	for _, currency := range fn.MDB.FXCurrencies(ctx) {
+		if strings.Compare(currency, CurrencyUSD) == 0 {
+			continue
+		}
+		st := fxHistoryStart
+		if last := fn.MDB.LastFXRate(ctx, currency); last != nil {
+			st = last.Date.AddDate(0, 0, 1)
+		}
+		if st.After(et) {
+			continue
+		}
+
+		rates, err := providers.GetFXHistory(currency, st, et)
+		if err != nil {
+			log.Printf("FX rates error - currency: %s error: %v", currency, err)
+			continue
+		}
+		if err := fn.MDB.FXRatesUpdate(ctx, rates); err != nil {
+			log.Printf("FX rates update error - currency: %s error: %v", currency, err)
+			continue
+		}
+		log.Printf("FX rates - currency: %s rates: %d", currency, len(rates))
+	}
+}
+
+func (fn *Finance) newFXRates(ctx context.Context) *fxRates {
+	return &fxRates{fn: fn, ctx: ctx, rates: make(map[string][]pricePoint)}
+}
+
+//rate returns the rate converting the currency to the other currency on the date.
+//It returns false when a currency other than USD has no rate on or before the date.
+func (fx *fxRates) rate(from string, to string, date time.Time) (float64, bool) {
+
+	if strings.Compare(from, to) == 0 {
+		return 1, true
+	}
+	fusd, ok := fx.usd(from, date)
+	if !ok {
+		return 0, false
+	}
+	tusd, ok := fx.usd(to, date)
+	if !ok {
+		return 0, false
+	}
+	return fusd / tusd, true
+}
+
+//usd returns the value in USD of one unit of the currency on the last day with a rate on or before the date.
+//It returns false when the date is before the first rate of the currency.
+func (fx *fxRates) usd(currency string, date time.Time) (float64, bool) {
+
+	if len(currency) == 0 || strings.Compare(currency, CurrencyUSD) == 0 {
+		return 1, true
+	}
+	pps, ok := fx.rates[currency]
+	if !ok {
+		for _, rate := range fx.fn.MDB.GetFXRates(fx.ctx, currency) {
+			pps = append(pps, pricePoint{rate.Date, rate.Rate})
+		}
+		fx.rates[currency] = pps
+	}
+	if len(pps) == 0 {
+		return 0, false
+	}
+	x := sort.Search(len(pps), func(i int) bool {
+		return pps[i].date.After(date)
+	})
+	if x == 0 {
+		return 0, false
+	}
+	return pps[x-1].price, true
+}
+
+//reportingCurrency returns the currency, or the reporting currency of the user when not set
+func (fn *Finance) reportingCurrency(ctx context.Context, currency string) string {
+
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if len(currency) == 0 {
+		currency = strings.ToUpper(fn.MDB.GetUserSettings(ctx).ReportingCurrency)
+	}
+	if len(currency) == 0 {
+		currency = CurrencyUSD
+	}
+	return currency
+}
+
+//convertLots converts the values of the priced lots to the currency. The cost is converted at the rate
+//on the date of the lot, the proceeds at the rate on the date of the sale and the market value at the rate on the date.
+//It returns an error when a currency of a lot has no rate, so values in different currencies are never summed.
+//Closed lots do not need a rate for the price currency, their last price is cleared without one.
+func (fn *Finance) convertLots(ctx context.Context, lots store.InvLots, currency string, now time.Time) error {
+
+	fx := fn.newFXRates(ctx)
+	for _, lot := range lots {
+
+		from := lot.Currency
+		if len(from) == 0 {
+			from = CurrencyUSD
+		}
+		prFrom := lot.PrCurrency
+		if len(prFrom) == 0 {
+			prFrom = from
+		}
+
+		date := now
+		if lot.Date != nil {
+			date = *lot.Date
+		}
+		open := strings.Compare(lot.Status, "O") == 0
+		crate, ok := fx.rate(from, currency, date)
+		if !ok {
+			return fmt.Errorf("no FX rate from %s to %s for %s in %s", from, currency, lot.Symbol, lot.Account)
+		}
+		prate, pok := fx.rate(prFrom, currency, now)
+		if !pok && open {
+			return fmt.Errorf("no FX rate from %s to %s for %s in %s", prFrom, currency, lot.Symbol, lot.Account)
+		}
+		srate := prate
+		if !open && lot.SaleDate != nil {
+			if srate, ok = fx.rate(from, currency, *lot.SaleDate); !ok {
+				return fmt.Errorf("no FX rate from %s to %s for %s in %s", from, currency, lot.Symbol, lot.Account)
+			}
+		}
+
+		lot.Cost *= crate
+		lot.CostValue *= crate
+		lot.PrLast *= prate
+		lot.PrDiffAmt *= prate
+		lot.Dglamount *= prate
+		lot.SalePrice *= srate
+		lot.MktValue *= srate
+		lot.Glamount = lot.MktValue - lot.CostValue
+		if lot.CostValue != 0 {
+			lot.Glperc = lot.Glamount * 100 / lot.CostValue
+		}
+		lot.Currency = currency
+		lot.PrCurrency = currency
+	}
+	return nil
+}
+
+//tickerCurrency returns the currency the ticker is priced in, the quote currency of crypto pairs when not set
+func tickerCurrency(t *store.Ticker) string {
+
+	if len(t.Currency) > 0 {
+		return strings.ToUpper(t.Currency)
+	}
+	if x := strings.LastIndex(t.Symbol, "-"); t.IsCrypto() && x > 0 {
+		return strings.ToUpper(t.Symbol[x+1:])
+	}
+	return CurrencyUSD
+}
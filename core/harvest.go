@@ -0,0 +1,166 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+)
+
+const (
+	//TermShort defines the lots held for a year or less
+	TermShort string = "short"
+	//TermLong defines the lots held for more than a year
+	TermLong string = "long"
+
+	//DefaultShortTermTaxRate is the tax rate in percent of short-term gains when not set in the user settings
+	DefaultShortTermTaxRate float64 = 24
+	//DefaultLongTermTaxRate is the tax rate in percent of long-term gains when not set in the user settings
+	DefaultLongTermTaxRate float64 = 15
+	//DefaultHarvestMinLoss is the smallest loss of a lot worth harvesting when not set
+	DefaultHarvestMinLoss float64 = 100
+
+	//WashSaleDays is the number of days before and after a sale a purchase disallows the loss
+	WashSaleDays int = 30
+)
+
+//maxReplacements is the number of replacement tickers suggested for a lot
+const maxReplacements = 5
+
+//InvestmentsHarvest returns the open taxable lots with losses of at least the amount and the percent, the tax saved by
+//selling them today and the purchases of the symbol in any account in the last 30 days that would make the sale a wash sale.
+//Replacements are tickers of the same industry. Crypto is not subject to the wash-sale rule.
+//The total tax savings exclude the lots with wash-sale conflicts.
+func (fn *Finance) InvestmentsHarvest(ctx context.Context, minLoss float64, minPerc float64) (*store.Harvest, error) {
+
+	if minLoss <= 0 {
+		minLoss = DefaultHarvestMinLoss
+	}
+	settings := fn.MDB.GetUserSettings(ctx)
+	stRate, ltRate := settings.ShortTermTaxRate, settings.LongTermTaxRate
+	if stRate <= 0 {
+		stRate = DefaultShortTermTaxRate
+	}
+	if ltRate <= 0 {
+		ltRate = DefaultLongTermTaxRate
+	}
+
+	lots := fn.taxLots(ctx, fn.getLots(ctx, "", "", "", "", true, true, false), TaxReportTaxable)
+
+	var symbols []string
+	for _, lot := range lots {
+		if symbol := tickerSymbol(lot.Symbol); !utils.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	tm := make(map[string]*store.Ticker)
+	for _, t := range fn.MDB.GetTickers(ctx, symbols) {
+		tm[t.Symbol] = t
+	}
+
+	now := time.Now()
+	buys := fn.recentBuys(ctx, now.AddDate(0, 0, -WashSaleDays))
+	replacements := make(map[string][]string)
+
+	hv := &store.Harvest{Lots: []*store.HarvestLot{}}
+	for _, lot := range lots {
+
+		if lot.Qty <= 0 || lot.PrLast <= 0 {
+			continue
+		}
+		loss := lot.MktValue - lot.CostValue
+		if -loss < minLoss || -lot.Glperc < minPerc {
+			continue
+		}
+
+		hl := &store.HarvestLot{Group: lot.Group, Category: lot.Category, Account: lot.Account, Symbol: lot.Symbol, Date: lot.Date}
+		hl.Qty = lot.Qty
+		hl.Cost = lot.Cost
+		hl.CostValue = utils.ToFixed(lot.CostValue, 2)
+		hl.PrLast = lot.PrLast
+		hl.MktValue = utils.ToFixed(lot.MktValue, 2)
+		hl.Loss = utils.ToFixed(loss, 2)
+		hl.LossPerc = utils.ToFixed(lot.Glperc, 2)
+		hl.Term, hl.TaxRate = TermShort, stRate
+		if longTerm(lot, now) {
+			hl.Term, hl.TaxRate = TermLong, ltRate
+		}
+		hl.TaxSavings = utils.ToFixed(-loss*hl.TaxRate/100, 2)
+
+		symbol := tickerSymbol(lot.Symbol)
+		t := tm[symbol]
+		hl.WashSaleBuys = []*store.WashSaleBuy{}
+		if t == nil || !t.IsCrypto() {
+			for _, actv := range buys[symbol] {
+				if actv.ID == lot.ActvID {
+					continue
+				}
+				hl.WashSaleBuys = append(hl.WashSaleBuys, &store.WashSaleBuy{Account: actv.Account, Date: actv.Date, Qty: actv.Qty, Price: actv.Price})
+			}
+			hl.WashSale = len(hl.WashSaleBuys) > 0
+		}
+
+		hl.Replacements = []string{}
+		if t != nil && len(t.Industry) > 0 {
+			if _, ok := replacements[t.Industry]; !ok {
+				replacements[t.Industry] = fn.industryTickers(ctx, t.Industry)
+			}
+			for _, rs := range replacements[t.Industry] {
+				if strings.Compare(rs, symbol) != 0 && len(hl.Replacements) < maxReplacements {
+					hl.Replacements = append(hl.Replacements, rs)
+				}
+			}
+		}
+
+		if strings.Compare(hl.Term, TermLong) == 0 {
+			hv.LongTermLoss += loss
+		} else {
+			hv.ShortTermLoss += loss
+		}
+		if !hl.WashSale {
+			hv.TaxSavings += hl.TaxSavings
+		}
+		hv.Lots = append(hv.Lots, hl)
+	}
+
+	sort.SliceStable(hv.Lots, func(i, j int) bool {
+		return hv.Lots[i].TaxSavings > hv.Lots[j].TaxSavings
+	})
+	hv.ShortTermLoss = utils.ToFixed(hv.ShortTermLoss, 2)
+	hv.LongTermLoss = utils.ToFixed(hv.LongTermLoss, 2)
+	hv.TaxSavings = utils.ToFixed(hv.TaxSavings, 2)
+	return hv, nil
+}
+
+//recentBuys returns the purchases in all accounts since the date by ticker symbol
+func (fn *Finance) recentBuys(ctx context.Context, since time.Time) map[string]store.Activities {
+
+	buys := make(map[string]store.Activities)
+	for _, actv := range fn.MDB.InvestmentsActivities(ctx, nil, true, false) {
+		if actv.Date.Before(since) {
+			break
+		}
+		if strings.Compare(actv.TxnType, "Buy") == 0 {
+			symbol := tickerSymbol(actv.Symbol)
+			buys[symbol] = append(buys[symbol], actv)
+		}
+	}
+	return buys
+}
+
+//industryTickers returns the symbols of the industry by market cap
+func (fn *Finance) industryTickers(ctx context.Context, industry string) []string {
+
+	var symbols []string
+	ts := store.TickerSearch{Industries: []string{industry}, PerfPeriod: "N"}
+	for _, t := range fn.MDB.SearchTickers(ctx, ts) {
+		symbols = append(symbols, t.Symbol)
+		if len(symbols) > maxReplacements {
+			break
+		}
+	}
+	return symbols
+}
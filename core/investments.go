@@ -8,10 +8,11 @@ import (
 	"time"
 
 	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
 )
 
-//InvestmentsHoldings returns the current holdings
-func (fn *Finance) InvestmentsHoldings(ctx context.Context, group string, category string, byAcct bool) (store.InvHoldings, error) {
+//InvestmentsHoldings returns the current holdings valued in the currency, or the reporting currency of the user when not set
+func (fn *Finance) InvestmentsHoldings(ctx context.Context, group string, category string, byAcct bool, currency string) (store.InvHoldings, error) {
 
 	lot := store.InvLot{}
 	lot.Group = group
@@ -19,6 +20,61 @@ func (fn *Finance) InvestmentsHoldings(ctx context.Context, group string, catego
 
 	lots := fn.getLots(ctx, group, category, "", "", true, true, false)
 	log.Printf("InvestmentsLots: %v", len(lots))
+	if err := fn.convertLots(ctx, lots, fn.reportingCurrency(ctx, currency), time.Now()); err != nil {
+		return nil, err
+	}
+
+	hs := holdingsFromLots(lots, byAcct)
+	return hs, nil
+}
+
+//InvestmentsHoldingsAsOf returns the holdings as they stood at the end of the date priced with the close on that date.
+//The open lots are rebuilt from the dates the stored lots were bought, received, sold and sent, and valued
+//in the currency, or the reporting currency of the user when not set, at the rates on that date.
+func (fn *Finance) InvestmentsHoldingsAsOf(ctx context.Context, date time.Time, group string, category string, byAcct bool, currency string) (store.InvHoldings, error) {
+
+	pd, err := fn.perfData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pd == nil {
+		return store.InvHoldings{}, nil
+	}
+
+	day := perfDate(date)
+	var lots store.InvLots
+	for _, lot := range fn.MDB.InvestmentsLots(ctx, &store.InvLot{Group: group, Category: category}, false, true) {
+		if lot.Qty = lotQtyAsOf(lot, day); lot.Qty <= 1e-9 {
+			continue
+		}
+		lot.Status = "O"
+
+		symbol := tickerSymbol(lot.Symbol)
+		lot.PrLast = pd.price(symbol, day)
+		lot.PrCurrency = pd.currencies[symbol]
+		lot.PrDiffAmt, lot.PrDiffPerc = utils.PriceDiff(lot.PrLast, pd.price(symbol, day.AddDate(0, 0, -1)))
+		lot.CostValue = lot.Qty * lot.Cost
+		lot.MktValue = lot.Qty * lot.PrLast
+		lot.Dglamount = lot.Qty * lot.PrDiffAmt
+		lot.Glamount = lot.MktValue - lot.CostValue
+		if lot.CostValue != 0 {
+			lot.Glperc = lot.Glamount * 100 / lot.CostValue
+		}
+		lots = append(lots, lot)
+	}
+	if err := fn.convertLots(ctx, lots, fn.reportingCurrency(ctx, currency), day); err != nil {
+		return nil, err
+	}
+
+	hs := holdingsFromLots(lots, byAcct)
+	for _, h := range hs {
+		h.Date = &day
+	}
+	return hs, nil
+}
+
+//holdingsFromLots sums the lots by symbol, and by account when byAcct is set
+func holdingsFromLots(lots store.InvLots, byAcct bool) store.InvHoldings {
 
 	hs := store.InvHoldings{}
 	hm := make(map[string]*store.InvHolding)
@@ -38,6 +94,7 @@ func (fn *Finance) InvestmentsHoldings(ctx context.Context, group string, catego
 			h.Category = lot.Category
 			h.Account = lot.Account
 			h.Symbol = lot.Symbol
+			h.Currency = lot.Currency
 			h.Qty = 0
 			h.Cost = 0
 			h.CostValue = 0
@@ -62,11 +119,34 @@ func (fn *Finance) InvestmentsHoldings(ctx context.Context, group string, catego
 
 	}
 
-	return hs, nil
+	return hs
 }
 
-//InvestmentsGainLoss returns all sales lots for the date range
-func (fn *Finance) InvestmentsGainLoss(ctx context.Context, group string, category string, ft time.Time, et time.Time) store.InvLots {
+//lotQtyAsOf returns the quantity of the stored lot held in its account at the end of the day. The lot is held
+//from its date, or from the date it was received when sent from another account. The quantity sold or sent
+//from the lot is held until the date of the sale or send.
+func lotQtyAsOf(lot *store.InvLot, day time.Time) float64 {
+
+	start := lot.Date
+	if lot.RecvDate != nil {
+		start = lot.RecvDate
+	}
+	if start == nil || perfDate(*start).After(day) {
+		return 0
+	}
+	qty := lot.Qty
+	if lot.SaleDate != nil && perfDate(*lot.SaleDate).After(day) {
+		qty += lot.SaleQty
+	}
+	if lot.SendDate != nil && perfDate(*lot.SendDate).After(day) {
+		qty += lot.SendQty
+	}
+	return qty
+}
+
+//InvestmentsGainLoss returns all sales lots for the date range in the accounts with the tax treatment.
+//The cost and proceeds are converted to the currency at the rates on the purchase and sale dates.
+func (fn *Finance) InvestmentsGainLoss(ctx context.Context, group string, category string, tax string, currency string, ft time.Time, et time.Time) (store.InvLots, error) {
 
 	// var rlots store.InvLots
 	log.Printf("InvestmentsGainLoss - Group: %s Category: %s Dates = %v to: %v", group, category, ft, et)
@@ -79,11 +159,14 @@ func (fn *Finance) InvestmentsGainLoss(ctx context.Context, group string, catego
 	// log.Println(len(lots))
 
 	fn.setLots(ctx, lots)
-	return lots
+	if err := fn.convertLots(ctx, lots, fn.reportingCurrency(ctx, currency), time.Now()); err != nil {
+		return nil, err
+	}
+	return fn.taxLots(ctx, lots, tax), nil
 }
 
-//InvestmentsGainLoss returns all sales lots for the date range
-func (fn *Finance) InvestmentsRewards(ctx context.Context, group string, category string, open bool, ft time.Time, et time.Time) store.InvLots {
+//InvestmentsRewards returns all rewards lots for the date range in the accounts with the tax treatment
+func (fn *Finance) InvestmentsRewards(ctx context.Context, group string, category string, tax string, open bool, ft time.Time, et time.Time) store.InvLots {
 
 	// var rlots store.InvLots
 	lot := &store.InvLot{}
@@ -99,7 +182,7 @@ func (fn *Finance) InvestmentsRewards(ctx context.Context, group string, categor
 			lot.CostValue = lot.OrigQty * lot.Cost
 		}
 	}
-	return lots
+	return fn.taxLots(ctx, lots, tax)
 }
 
 //InvestmentsLots returns lots
@@ -160,7 +243,7 @@ func (fn *Finance) setLots(ctx context.Context, lots store.InvLots) {
 	var lot *store.InvLot
 
 	for _, lot = range lots {
-		symbols = append(symbols, lot.Symbol)
+		symbols = append(symbols, tickerSymbol(lot.Symbol))
 	}
 
 	tm := make(map[string]*store.Ticker)
@@ -186,12 +269,7 @@ func (fn *Finance) setLots(ctx context.Context, lots store.InvLots) {
 		// 	log.Printf("Lots - Account: %s Symbol: %s Status: %v Qty: %f Cost: %f CostValue: %f", lot.Account, lot.Symbol, lot.Status, lot.Qty, lot.Cost, lot.CostValue)
 		// }
 
-		if strings.Compare(lot.Symbol, "ETH2-USD") == 0 ||
-			strings.Compare(lot.Symbol, "WETH-USD") == 0 {
-			ticker = tm["ETH-USD"]
-		} else {
-			ticker = tm[lot.Symbol]
-		}
+		ticker = tm[tickerSymbol(lot.Symbol)]
 
 		if ticker == nil {
 			log.Printf("Ticker: %s not found", lot.Symbol)
@@ -199,6 +277,7 @@ func (fn *Finance) setLots(ctx context.Context, lots store.InvLots) {
 		} else {
 
 			lot.PrLast = ticker.PrLast
+			lot.PrCurrency = tickerCurrency(ticker)
 			lot.PrDiffAmt = ticker.PrDiffAmt
 			lot.PrDiffPerc = ticker.PrDiffPerc
 
@@ -2,6 +2,8 @@ package core
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"strings"
 	"time"
@@ -92,16 +94,10 @@ func (fn *Finance) UpdateTickers(ctx context.Context, tickers store.Tickers, upd
 	// log.Println("Updating tickers started...")
 	for _, ticker := range tickers {
 
-		if ticker.IsStock() {
-			url, err := providers.UpdateTickerDetails(ticker)
-			time.Sleep(1000 * time.Millisecond)
-
-			if err != nil {
-				log.Println(url)
-				log.Println(err)
-				// log.Println(ticker.FormatTickerData(";"))
-				// continue
-			}
+		if _, err := fn.Providers.Fundamentals(ticker); err != nil {
+			log.Printf("Ticker: %s fundamentals error: %v", ticker.Symbol, err)
+			// log.Println(ticker.FormatTickerData(";"))
+			// continue
 		}
 
 		err := fn.AddTicker(ctx, ticker)
@@ -130,6 +126,8 @@ func (fn *Finance) UpdateStocksEOD(ctx context.Context) {
 	if tickers != nil {
 		log.Printf("Updating %d tickers.", len(tickers))
 		fn.updateEOD(ctx, tickers, true, false)
+		fn.UpdateFXRates(ctx)
+		fn.SnapshotHoldings(ctx)
 	} else {
 		log.Printf("No tickers to update.")
 	}
@@ -143,6 +141,7 @@ func (fn *Finance) UpdateCryptosEOD(ctx context.Context) {
 	if tickers != nil {
 		log.Printf("Updating %d crypto tickers.", len(tickers))
 		fn.updateEOD(ctx, tickers, true, false)
+		fn.SnapshotHoldings(ctx)
 	} else {
 		log.Printf("No crypto tickers to update.")
 	}
@@ -168,19 +167,26 @@ func (fn *Finance) updateEOD(ctx context.Context, tickers store.Tickers, updateH
 	for _, ticker := range tickers {
 
 		// tha := thm[ticker.GetTickerID()]
-		tha := fn.updateTickerHistory(ctx, ticker)
+		tha, source, err := fn.updateTickerHistory(ctx, ticker)
+		if err != nil {
+			log.Printf("Ticker: %s history error: %v", ticker.GetTickerID(), err)
+		}
 
 		// log.Printf("Ticker: %s hist: %d\n", ticker.Symbol, len(tha))
 		if len(tha) == 0 {
 			log.Printf("Ticker: %s history not available.", ticker.GetTickerID())
 			// continue
-			prLast, err := providers.GetTickerPriceFromBinance(ticker.Symbol)
+			qm, err := fn.quotes(store.Tickers{ticker})
 			if err != nil {
+				log.Printf("Ticker: %s quote error: %v", ticker.GetTickerID(), err)
+			}
+			q := qm[ticker.GetTickerID()]
+			if q == nil {
 				continue
 			}
-			// log.Printf("Ticker price from binance %f", prLast)
-			ticker.PrLast = prLast
-			ticker.PrClose = prLast
+			ticker.PrLast = q.Price
+			ticker.PrClose = q.Price
+			ticker.PrSource = q.Source
 			if ticker.PrPrev != 0.0 {
 				ticker.SetPriceDiff()
 			}
@@ -207,6 +213,7 @@ func (fn *Finance) updateEOD(ctx context.Context, tickers store.Tickers, updateH
 			if pth != nil {
 				ticker.PrPrev = pth.Close
 			}
+			ticker.PrSource = source
 			ticker.SetPriceDiff()
 			fn.updatePerformance(ctx, ticker, tha)
 
@@ -268,9 +275,10 @@ func (fn *Finance) updateRealtime(ctx context.Context, tickers store.Tickers) {
 	var uts store.Tickers
 	today := time.Now()
 
-	tom := time.Now().Add(time.Hour * 48)
-	ctm := providers.GetCryptoHistory(tickers, today, tom)
-	itm, ptm := providers.GetTickerQuotes(tickers)
+	qm, err := fn.quotes(tickers)
+	if err != nil {
+		log.Printf("Quotes error: %v", err)
+	}
 
 	// var debugSymbols string
 	// debugSymbols = "AAPL,ZG"
@@ -285,10 +293,12 @@ func (fn *Finance) updateRealtime(ctx context.Context, tickers store.Tickers) {
 		// 	continue
 		// }
 
+		q := qm[ticker.GetTickerID()]
 		if ticker.IsStock() {
 
-			lp, date := providers.GetTickerRealTimeQuote(*ticker)
-			if date != nil && utils.DateEqual(today, *date) {
+			if q != nil && q.Date != nil && utils.DateEqual(today, *q.Date) {
+
+				date, lp := q.Date, q.Price
 
 				if ticker.PrDate == nil || !utils.DateEqual(*date, *ticker.PrDate) {
 					ticker.PrDate = date
@@ -296,6 +306,7 @@ func (fn *Finance) updateRealtime(ctx context.Context, tickers store.Tickers) {
 				}
 				// log.Printf("lastprice: %f", lp)
 				ticker.PrLast = formatDec(lp)
+				ticker.PrSource = q.Source
 				ticker.SetPriceDiff()
 				// if strings.Contains(debugSymbols, ticker.Symbol) {
 				// 	log.Printf("Stock --- %v", ticker.PrLast)
@@ -305,26 +316,13 @@ func (fn *Finance) updateRealtime(ctx context.Context, tickers store.Tickers) {
 
 		} else if ticker.IsCrypto() {
 
-			tha := ctm[ticker.GetTickerID()]
-			if tha == nil || len(tha) == 0 {
+			if q != nil {
 
-				prLast, err := providers.GetTickerPriceFromBinance(ticker.Symbol)
-				if err != nil {
-					continue
-				}
-				// log.Printf("Binance price - %s: %f", ticker.Symbol, prLast)
-				ticker.PrLast = prLast
-				if ticker.PrPrev != 0.0 {
-					ticker.SetPriceDiff()
+				ticker.PrLast = formatDec(q.Price)
+				if q.Date != nil {
+					ticker.PrDate = q.Date
 				}
-
-			} else {
-				// if tha != nil && len(tha) > 0 {
-
-				th := tha[len(tha)-1]
-				// log.Println(th)
-				ticker.PrLast = formatDec(th.Close)
-				ticker.PrDate = &th.Date
+				ticker.PrSource = q.Source
 				ticker.SetPriceDiff()
 				// if strings.Contains(debugSymbols, ticker.Symbol) {
 				// log.Printf("Crypto --- %s", ticker.FormatPriceDiff())
@@ -333,21 +331,19 @@ func (fn *Finance) updateRealtime(ctx context.Context, tickers store.Tickers) {
 			}
 		} else if ticker.IsIndex() {
 
-			ih := itm[ticker.GetTickerID()]
-			if ih != nil {
+			if q != nil {
 				// log.Printf("Date: %v close: %f", ih.Date, ih.Close)
 				// if ticker.PrDate == nil || !utils.DateEqual(*date, *ticker.PrDate) {
 				// 	ticker.PrDate = date
 				// 	ticker.PrPrev = ticker.PrLast
 				// }
-				ph := ptm[ticker.GetTickerID()]
-				if ph != nil {
-					ticker.PrDate = &ph.Date
-					ticker.PrPrev = ph.Close
+				if q.Prev != 0 {
+					ticker.PrPrev = q.Prev
 				}
 
-				ticker.PrLast = formatDec(ih.Close)
-				ticker.PrDate = &ih.Date
+				ticker.PrLast = formatDec(q.Price)
+				ticker.PrDate = q.Date
+				ticker.PrSource = q.Source
 				ticker.SetPriceDiff()
 				// if strings.Contains(debugSymbols, ticker.Symbol) {
 				// log.Printf("Index --- %s", ticker.FormatPriceDiff())
@@ -401,20 +397,15 @@ func (fn *Finance) updatePerformance(ctx context.Context, ticker *store.Ticker,
 	}
 }
 
-func (fn *Finance) updateTickerHistory(ctx context.Context, ticker *store.Ticker) []*store.TickerHistory {
-
-	var thm = make(map[string][]*store.TickerHistory)
-	var tha []*store.TickerHistory
+//updateTickerHistory returns the history of the ticker with the technicals, the provider of the history
+//and the error of the providers failing
+func (fn *Finance) updateTickerHistory(ctx context.Context, ticker *store.Ticker) ([]*store.TickerHistory, string, error) {
 
 	et := time.Now()
 	st := time.Now().Add(-time.Hour * 24 * 365 * 6)
 
-	if ticker.IsCrypto() {
-		thm = providers.GetCryptoHistory(store.Tickers{ticker}, st, et)
-	} else {
-		thm = providers.GetTickersHistory(store.Tickers{ticker}, st, et)
-	}
-	tha = thm[ticker.GetTickerID()]
+	thm, sources, err := fn.Providers.History(ticker.AssetClass(), store.Tickers{ticker}, st, et)
+	tha := thm[ticker.GetTickerID()]
 
 	// log.Println(len(tha))
 	for _, th := range tha {
@@ -426,13 +417,59 @@ func (fn *Finance) updateTickerHistory(ctx context.Context, ticker *store.Ticker
 
 	updateRSI(tha)
 	updateMAs(tha)
-	return tha
+	return tha, sources[ticker.GetTickerID()], err
 }
 
 func (fn *Finance) UpdateTickersNews(ctx context.Context) {
 
 	tickers := fn.MDB.GetTickers(ctx, []string{})
 
-	tnm := providers.GetTickerNews(tickers)
+	tnm := make(map[string][]store.TickerNews)
+	for class, ts := range tickersByAssetClass(tickers) {
+		ctnm, err := fn.Providers.News(class, ts)
+		if err != nil {
+			log.Printf("News error - class: %s error: %v", class, err)
+		}
+		for id, tns := range ctnm {
+			tnm[id] = tns
+		}
+	}
 	fn.MDB.UpdateTickersNews(ctx, tnm)
 }
+
+//quotes returns the latest quotes of the tickers from the quote providers of their asset class by ticker id.
+//It returns the errors of the providers failing with the quotes found.
+func (fn *Finance) quotes(tickers store.Tickers) (map[string]*providers.Quote, error) {
+
+	var errs []string
+	qm := make(map[string]*providers.Quote)
+	for class, ts := range tickersByAssetClass(tickers) {
+		cqm, err := fn.Providers.Quotes(class, ts)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", class, err))
+		}
+		for id, q := range cqm {
+			qm[id] = q
+		}
+	}
+	if len(errs) > 0 {
+		return qm, errors.New(strings.Join(errs, "; "))
+	}
+	return qm, nil
+}
+
+//ProvidersHealth returns the health of the market data providers
+func (fn *Finance) ProvidersHealth() []*providers.ProviderHealth {
+	return fn.Providers.Health()
+}
+
+//tickersByAssetClass groups the tickers by asset class
+func tickersByAssetClass(tickers store.Tickers) map[string]store.Tickers {
+
+	tm := make(map[string]store.Tickers)
+	for _, ticker := range tickers {
+		class := ticker.AssetClass()
+		tm[class] = append(tm[class], ticker)
+	}
+	return tm
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+)
+
+//Balances returns the balances of the account
+func (fn *Finance) Balances(ctx context.Context, account string) store.Balances {
+	bals := fn.MDB.Balances(ctx, account, nil)
+	if bals == nil {
+		bals = store.Balances{}
+	}
+	return bals
+}
+
+//NetWorth returns the net worth at the end of each of the last months in the reporting currency.
+//Balances are carried forward from the latest valuation on or before the month end,
+//investments from the latest snapshot of the holdings. Both are converted at the rates on the month end.
+func (fn *Finance) NetWorth(ctx context.Context, months int) (store.NetWorths, error) {
+
+	if months <= 0 {
+		months = 12
+	}
+
+	now := time.Now()
+	bals := fn.MDB.Balances(ctx, "", &now)
+	currency := fn.reportingCurrency(ctx, "")
+	fx := fn.newFXRates(ctx)
+
+	hs, err := fn.InvestmentsHoldings(ctx, "", "", false, currency)
+	if err != nil {
+		return nil, err
+	}
+	var mktValue float64
+	for _, h := range hs {
+		mktValue += h.MktValue
+	}
+	snaps := fn.MDB.Snapshots(ctx, nil, &now, false)
+
+	nws := store.NetWorths{}
+	for _, date := range monthEnds(now, months) {
+
+		nw := &store.NetWorth{}
+		nw.Date = date
+		nw.Currency = currency
+		nw.Accounts = make(map[string]float64)
+
+		//balances are sorted by date, the last one on or before the date wins
+		lm := make(map[string]*store.Balance)
+		for _, bal := range bals {
+			if bal.Date.After(*date) {
+				break
+			}
+			lm[bal.Account] = bal
+		}
+
+		for account, bal := range lm {
+			rate, ok := fx.rate(balanceCurrency(bal.Currency), currency, *date)
+			if !ok {
+				return nil, fmt.Errorf("no FX rate from %s to %s for %s", balanceCurrency(bal.Currency), currency, account)
+			}
+			value := bal.Value * rate
+			if bal.IsLiability() {
+				nw.Liabilities += value
+				nw.Accounts[account] = utils.ToFixed(-value, 2)
+			} else {
+				nw.Assets += value
+				nw.Accounts[account] = utils.ToFixed(value, 2)
+			}
+		}
+
+		//the current month uses today's prices
+		if !date.Before(now) {
+			nw.Investments = utils.ToFixed(mktValue, 2)
+		} else {
+			var last *store.Snapshot
+			for _, snap := range snaps {
+				if snap.Date.After(*date) {
+					break
+				}
+				last = snap
+			}
+			if last != nil {
+				rate, ok := fx.rate(balanceCurrency(last.Currency), currency, *date)
+				if !ok {
+					return nil, fmt.Errorf("no FX rate from %s to %s for the snapshot on %s", balanceCurrency(last.Currency), currency, last.Date.Format("2006-01-02"))
+				}
+				nw.Investments = utils.ToFixed(last.MktValue*rate, 2)
+			}
+		}
+
+		nw.Assets = utils.ToFixed(nw.Assets, 2)
+		nw.Liabilities = utils.ToFixed(nw.Liabilities, 2)
+		nw.NetWorth = utils.ToFixed(nw.Assets+nw.Investments-nw.Liabilities, 2)
+		nws = append(nws, nw)
+	}
+
+	return nws, nil
+}
+
+//balanceCurrency returns the currency of a balance or snapshot, USD when not set
+func balanceCurrency(currency string) string {
+	if len(currency) == 0 {
+		return CurrencyUSD
+	}
+	return strings.ToUpper(currency)
+}
+
+//monthEnds returns the last moment of each of the months ending with the current date
+func monthEnds(now time.Time, months int) []*time.Time {
+
+	var dates []*time.Time
+	first := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	for x := months - 1; x > 0; x-- {
+		date := first.AddDate(0, -x+1, 0).Add(-time.Nanosecond)
+		dates = append(dates, &date)
+	}
+	date := now
+	dates = append(dates, &date)
+	return dates
+}
@@ -0,0 +1,480 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+)
+
+//DefaultBenchmarks are compared with the portfolio when the user has not chosen benchmarks
+var DefaultBenchmarks = []string{"SPY"}
+
+const (
+	//PerfPortfolio defines the performance of all accounts
+	PerfPortfolio string = "portfolio"
+	//PerfGroup defines the performance of the accounts of a group
+	PerfGroup string = "group"
+	//PerfAccount defines the performance of an account
+	PerfAccount string = "account"
+)
+
+//perfDay holds the value at the end of the day and the external cash flows during the day
+type perfDay struct {
+	date  time.Time
+	value float64
+	in    float64
+	out   float64
+}
+
+type pricePoint struct {
+	date  time.Time
+	price float64
+}
+
+//perfData holds the investment activities and the daily prices of the symbols.
+//The values of the days are converted to the currency at the rates of the day.
+type perfData struct {
+	actvs      store.Activities
+	prices     map[string][]pricePoint
+	currencies map[string]string
+	currency   string
+	fx         *fxRates
+	first      time.Time
+	last       time.Time
+}
+
+//perfScope selects the activities of the portfolio, a group or an account
+type perfScope struct {
+	level string
+	name  string
+}
+
+//InvestmentsPerformance returns the time-weighted and money-weighted returns over the performance periods
+//for the portfolio, each group and each account. Level limits the results to one of them.
+//Buys, sales and transfers into or out of the scope are external cash flows, rewards are returns.
+//Each period is compared with the benchmarks, or the benchmarks in the user settings when not set.
+func (fn *Finance) InvestmentsPerformance(ctx context.Context, level string, benchmarks []string) (store.Performances, error) {
+
+	pd, err := fn.perfData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	perfs := store.Performances{}
+	if pd == nil {
+		return perfs, nil
+	}
+
+	if len(benchmarks) == 0 {
+		benchmarks = fn.MDB.GetUserSettings(ctx).Benchmarks
+	}
+	if len(benchmarks) == 0 {
+		benchmarks = DefaultBenchmarks
+	}
+	var bsymbols []string
+	for _, symbol := range benchmarks {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if len(symbol) > 0 && !utils.Contains(bsymbols, symbol) {
+			bsymbols = append(bsymbols, symbol)
+		}
+	}
+	fn.loadPrices(ctx, pd, bsymbols)
+
+	for _, scope := range pd.scopes(level) {
+		days := pd.scopeDays(scope)
+		perf := &store.Performance{Level: scope.level, Name: scope.name, Currency: pd.currency}
+		perf.Value = utils.ToFixed(days[len(days)-1].value, 2)
+		perf.Returns = []*store.PerfReturn{}
+		for _, period := range store.PerfPeriods {
+			if pr := periodReturn(days, period); pr != nil {
+				for _, symbol := range bsymbols {
+					if br := pd.benchmarkReturn(days, pr, symbol); br != nil {
+						pr.Benchmarks = append(pr.Benchmarks, br)
+					}
+				}
+				perf.Returns = append(perf.Returns, pr)
+			}
+		}
+		perfs = append(perfs, perf)
+	}
+	return perfs, nil
+}
+
+//perfData loads the investment activities and the price history of their symbols.
+//It returns an error when a currency has no rate to the reporting currency from the first activity.
+func (fn *Finance) perfData(ctx context.Context) (*perfData, error) {
+
+	actvs := fn.MDB.InvestmentsActivities(ctx, nil, false, true)
+	if len(actvs) == 0 {
+		return nil, nil
+	}
+
+	pd := &perfData{actvs: actvs, prices: make(map[string][]pricePoint), currencies: make(map[string]string)}
+	pd.currency = fn.reportingCurrency(ctx, "")
+	pd.fx = fn.newFXRates(ctx)
+	pd.first = perfDate(*actvs[0].Date)
+	pd.last = perfDate(time.Now())
+
+	var symbols []string
+	for _, actv := range actvs {
+		symbol := tickerSymbol(actv.Symbol)
+		if !utils.Contains(symbols, symbol) {
+			symbols = append(symbols, symbol)
+			pd.currencies[symbol] = actv.Currency
+		}
+		//trade prices fill the gaps in the history
+		if actv.Price > 0 {
+			pd.prices[symbol] = append(pd.prices[symbol], pricePoint{perfDate(*actv.Date), actv.Price})
+		}
+	}
+	fn.loadPrices(ctx, pd, symbols)
+
+	var currencies []string
+	for _, actv := range actvs {
+		if !utils.Contains(currencies, actv.Currency) {
+			currencies = append(currencies, actv.Currency)
+		}
+	}
+	for _, currency := range pd.currencies {
+		if !utils.Contains(currencies, currency) {
+			currencies = append(currencies, currency)
+		}
+	}
+	for _, currency := range currencies {
+		if _, ok := pd.fx.rate(currency, pd.currency, pd.first); !ok {
+			return nil, fmt.Errorf("no FX rate from %s to %s on %s", currency, pd.currency, pd.first.Format("2006-01-02"))
+		}
+	}
+	return pd, nil
+}
+
+//loadPrices adds the close history and the last price of the symbols to the prices
+func (fn *Finance) loadPrices(ctx context.Context, pd *perfData, symbols []string) {
+
+	for _, symbol := range symbols {
+		for _, th := range fn.MDB.GetTickerHistory(ctx, symbol) {
+			if th.Close > 0 {
+				pd.prices[symbol] = append(pd.prices[symbol], pricePoint{perfDate(th.Date), th.Close})
+			}
+		}
+	}
+	for _, t := range fn.MDB.GetTickers(ctx, symbols) {
+		if t.PrLast > 0 {
+			pd.prices[t.Symbol] = append(pd.prices[t.Symbol], pricePoint{pd.last, t.PrLast})
+		}
+		pd.currencies[t.Symbol] = tickerCurrency(t)
+	}
+
+	for _, symbol := range symbols {
+		pps := pd.prices[symbol]
+		sort.SliceStable(pps, func(i, j int) bool {
+			return pps[i].date.Before(pps[j].date)
+		})
+	}
+}
+
+//scopes returns the portfolio, groups and accounts of the activities for the level
+func (pd *perfData) scopes(level string) []perfScope {
+
+	var scopes []perfScope
+	if len(level) == 0 || strings.Compare(level, PerfPortfolio) == 0 {
+		scopes = append(scopes, perfScope{level: PerfPortfolio})
+	}
+
+	var groups, accounts []string
+	for _, actv := range pd.actvs {
+		if !utils.Contains(groups, actv.Group) {
+			groups = append(groups, actv.Group)
+		}
+		if !utils.Contains(accounts, actv.Account) {
+			accounts = append(accounts, actv.Account)
+		}
+		if len(actv.ToAccount) > 0 && !utils.Contains(accounts, actv.ToAccount) {
+			accounts = append(accounts, actv.ToAccount)
+		}
+	}
+	sort.Strings(groups)
+	sort.Strings(accounts)
+
+	if len(level) == 0 || strings.Compare(level, PerfGroup) == 0 {
+		for _, group := range groups {
+			scopes = append(scopes, perfScope{level: PerfGroup, name: group})
+		}
+	}
+	if len(level) == 0 || strings.Compare(level, PerfAccount) == 0 {
+		for _, account := range accounts {
+			scopes = append(scopes, perfScope{level: PerfAccount, name: account})
+		}
+	}
+	return scopes
+}
+
+func (scope perfScope) contains(account string, group string) bool {
+	switch scope.level {
+	case PerfGroup:
+		return strings.Compare(group, scope.name) == 0
+	case PerfAccount:
+		return strings.Compare(account, scope.name) == 0
+	}
+	return true
+}
+
+//scopeDays returns the value and cash flows of the scope for each day from the first activity to today
+func (pd *perfData) scopeDays(scope perfScope) []perfDay {
+
+	var days []perfDay
+	pos := make(map[string]float64)
+	x := 0
+	for date := pd.first; !date.After(pd.last); date = date.AddDate(0, 0, 1) {
+
+		day := perfDay{date: date}
+		for ; x < len(pd.actvs) && !perfDate(*pd.actvs[x].Date).After(date); x++ {
+			actv := pd.actvs[x]
+			symbol := tickerSymbol(actv.Symbol)
+			src := scope.contains(actv.Account, actv.Group)
+			rate := pd.rate(actv.Currency, date)
+			value := actv.Qty * actv.Price * rate
+			fee := actv.Fee * rate
+			if value == 0 {
+				value = actv.Qty * pd.value(symbol, date)
+			}
+
+			switch actv.TxnType {
+			case "Buy":
+				if src {
+					pos[symbol] += actv.Qty
+					day.in += value + fee
+				}
+			case "Receive":
+				if src {
+					pos[symbol] += actv.Qty
+					day.in += value
+				}
+			case "Rewards":
+				if src {
+					pos[symbol] += actv.Qty
+				}
+			case "Sale":
+				if src {
+					pos[symbol] -= actv.Qty
+					day.out += value - fee
+				}
+			case "Send":
+				dst := len(actv.ToAccount) > 0 && scope.contains(actv.ToAccount, actv.Group)
+				value = actv.Qty * pd.value(symbol, date)
+				if src {
+					pos[symbol] -= actv.Qty
+				}
+				if dst {
+					pos[symbol] += actv.Qty
+				}
+				if src && !dst {
+					day.out += value
+				} else if dst && !src {
+					day.in += value
+				}
+			}
+		}
+
+		for symbol, qty := range pos {
+			if qty < 1e-9 {
+				pos[symbol] = 0
+				continue
+			}
+			day.value += qty * pd.value(symbol, date)
+		}
+		days = append(days, day)
+	}
+	return days
+}
+
+//value returns the price of the symbol on the date converted to the currency of the data
+func (pd *perfData) value(symbol string, date time.Time) float64 {
+	return pd.price(symbol, date) * pd.rate(pd.currencies[symbol], date)
+}
+
+//rate returns the rate converting the currency to the currency of the data on the date.
+//The rates of the currencies were checked from the first day when the data was loaded.
+func (pd *perfData) rate(currency string, date time.Time) float64 {
+	rate, _ := pd.fx.rate(currency, pd.currency, date)
+	return rate
+}
+
+//price returns the last price of the symbol on or before the date, or the first price after it
+func (pd *perfData) price(symbol string, date time.Time) float64 {
+
+	pps := pd.prices[symbol]
+	if len(pps) == 0 {
+		return 0
+	}
+	x := sort.Search(len(pps), func(i int) bool {
+		return pps[i].date.After(date)
+	})
+	if x == 0 {
+		return pps[0].price
+	}
+	return pps[x-1].price
+}
+
+//periodReturn returns the returns from the start of the period, or nil if nothing was held during the period
+func periodReturn(days []perfDay, period string) *store.PerfReturn {
+
+	pdate := utils.DateForPeriod(period)
+	if pdate == nil {
+		return nil
+	}
+	start := perfDate(*pdate)
+	x := sort.Search(len(days), func(i int) bool {
+		return !days[i].date.Before(start)
+	})
+	if x >= len(days) {
+		return nil
+	}
+
+	pr := &store.PerfReturn{Period: period}
+	date := days[x].date
+	pr.StartDate = &date
+	if x > 0 {
+		pr.StartValue = days[x-1].value
+	}
+
+	var amounts []float64
+	var dates []time.Time
+	if pr.StartValue > 0 {
+		amounts = append(amounts, -pr.StartValue)
+		dates = append(dates, date)
+	}
+
+	twr := 1.0
+	prev := pr.StartValue
+	for _, day := range days[x:] {
+		if den := prev + day.in; den > 0 {
+			twr *= (day.value + day.out) / den
+		}
+		if day.in != 0 || day.out != 0 {
+			amounts = append(amounts, day.out-day.in)
+			dates = append(dates, day.date)
+		}
+		pr.NetFlows += day.in - day.out
+		prev = day.value
+	}
+
+	end := days[len(days)-1]
+	pr.EndValue = end.value
+	if pr.StartValue == 0 && pr.EndValue == 0 && len(amounts) == 0 {
+		return nil
+	}
+	amounts = append(amounts, end.value)
+	dates = append(dates, end.date)
+
+	pr.Gain = utils.ToFixed(pr.EndValue-pr.StartValue-pr.NetFlows, 2)
+	pr.TWR = utils.ToFixed((twr-1)*100, 2)
+	if rate, ok := xirr(amounts, dates); ok {
+		pr.XIRR = utils.ToFixed(rate*100, 2)
+	}
+	pr.StartValue = utils.ToFixed(pr.StartValue, 2)
+	pr.EndValue = utils.ToFixed(pr.EndValue, 2)
+	pr.NetFlows = utils.ToFixed(pr.NetFlows, 2)
+	return pr
+}
+
+//benchmarkReturn compares the daily returns of the scope with the benchmark over the period.
+//Alpha and tracking error are annualized over calendar days as the days include weekends.
+func (pd *perfData) benchmarkReturn(days []perfDay, pr *store.PerfReturn, symbol string) *store.BenchmarkReturn {
+
+	x := sort.Search(len(days), func(i int) bool {
+		return !days[i].date.Before(*pr.StartDate)
+	})
+	first := pd.price(symbol, pr.StartDate.AddDate(0, 0, -1))
+	last := pd.price(symbol, days[len(days)-1].date)
+	if first <= 0 || last <= 0 {
+		return nil
+	}
+
+	var prets, brets, diffs []float64
+	prev := 0.0
+	if x > 0 {
+		prev = days[x-1].value
+	}
+	for _, day := range days[x:] {
+		den := prev + day.in
+		b0 := pd.price(symbol, day.date.AddDate(0, 0, -1))
+		if den > 0 && b0 > 0 {
+			pret := (day.value+day.out)/den - 1
+			bret := pd.price(symbol, day.date)/b0 - 1
+			prets = append(prets, pret)
+			brets = append(brets, bret)
+			diffs = append(diffs, pret-bret)
+		}
+		prev = day.value
+	}
+
+	br := &store.BenchmarkReturn{Symbol: symbol}
+	br.Return = utils.ToFixed((last/first-1)*100, 2)
+	if len(prets) < 2 {
+		return br
+	}
+	beta := 0.0
+	if variance := utils.Covariance(brets, brets); variance > 0 {
+		beta = utils.Covariance(prets, brets) / variance
+	}
+	br.Beta = utils.ToFixed(beta, 2)
+	br.Alpha = utils.ToFixed((utils.Mean(prets)-beta*utils.Mean(brets))*365*100, 2)
+	br.TrackingError = utils.ToFixed(utils.StdDev(diffs)*math.Sqrt(365)*100, 2)
+	return br
+}
+
+//xirr returns the annual rate at which the net present value of the cash flows is zero
+func xirr(amounts []float64, dates []time.Time) (float64, bool) {
+
+	if len(amounts) < 2 {
+		return 0, false
+	}
+	npv := func(rate float64) float64 {
+		var sum float64
+		for x, amount := range amounts {
+			years := dates[x].Sub(dates[0]).Hours() / 24 / 365
+			sum += amount / math.Pow(1+rate, years)
+		}
+		return sum
+	}
+
+	lo, hi := -0.9999, 10.0
+	flo, fhi := npv(lo), npv(hi)
+	if math.IsNaN(flo) || math.IsNaN(fhi) || flo*fhi > 0 {
+		return 0, false
+	}
+	for x := 0; x < 200; x++ {
+		mid := (lo + hi) / 2
+		fmid := npv(mid)
+		if math.Abs(fmid) < 1e-7 {
+			return mid, true
+		}
+		if flo*fmid < 0 {
+			hi = mid
+		} else {
+			lo, flo = mid, fmid
+		}
+	}
+	return (lo + hi) / 2, true
+}
+
+//perfDate returns the day of the date
+func perfDate(date time.Time) time.Time {
+	date = date.UTC()
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+//tickerSymbol returns the ticker the symbol is priced from
+func tickerSymbol(symbol string) string {
+	if strings.Compare(symbol, "ETH2-USD") == 0 ||
+		strings.Compare(symbol, "WETH-USD") == 0 {
+		return "ETH-USD"
+	}
+	return symbol
+}
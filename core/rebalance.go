@@ -0,0 +1,350 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//DefaultDriftBand is the drift in percentage points a slice may move from its target before it is rebalanced
+const DefaultDriftBand float64 = 5
+
+//rebalanceSlice holds the holdings of a slice and the value to buy or sell
+type rebalanceSlice struct {
+	drift *store.RebalanceDrift
+	items []*allocItem
+	trade float64
+}
+
+//AllocTargets returns the target allocations of the user
+func (fn *Finance) AllocTargets(ctx context.Context) store.AllocTargets {
+	targets := fn.MDB.AllocTargets(ctx, "")
+	if targets == nil {
+		targets = store.AllocTargets{}
+	}
+	return targets
+}
+
+//AllocTargetsUpdate validates and updates the target allocations
+func (fn *Finance) AllocTargetsUpdate(ctx context.Context, targets store.AllocTargets) error {
+
+	for _, target := range targets {
+		switch target.Dimension {
+		case AllocSymbol:
+			target.Name = strings.ToUpper(strings.TrimSpace(target.Name))
+		case AllocAssetClass, AllocSector, AllocIndustry:
+			target.Name = strings.TrimSpace(target.Name)
+		default:
+			return fmt.Errorf("Target dimension %s is not valid", target.Dimension)
+		}
+		if len(target.Name) == 0 {
+			return fmt.Errorf("Name is required for the %s target", target.Dimension)
+		}
+		if target.Weight < 0 || target.Weight > 100 {
+			return fmt.Errorf("Weight of %s must be between 0 and 100", target.Name)
+		}
+	}
+	return fn.MDB.AllocTargetsUpdate(ctx, targets)
+}
+
+//AllocTargetDelete deletes the target allocation
+func (fn *Finance) AllocTargetDelete(ctx context.Context, id primitive.ObjectID) error {
+	return fn.MDB.DeleteAllocTarget(ctx, id)
+}
+
+//InvestmentsRebalance compares the holdings with the targets of the dimension and returns the trades that rebalance them.
+//Only the slices drifting beyond the band are rebalanced, the slices within the band absorb the difference.
+//Sales come from tax-advantaged accounts first, then from the taxable lots with losses, long-term gains
+//and short-term gains. Buys add to the largest holding of the slice.
+func (fn *Finance) InvestmentsRebalance(ctx context.Context, dimension string, band float64) (*store.Rebalance, error) {
+
+	targets := fn.MDB.AllocTargets(ctx, dimension)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("No target allocations are defined")
+	}
+	var dims []string
+	weight := 0.0
+	for _, target := range targets {
+		if !utils.Contains(dims, target.Dimension) {
+			dims = append(dims, target.Dimension)
+		}
+		weight += target.Weight
+	}
+	if len(dims) > 1 {
+		return nil, fmt.Errorf("Targets are defined for the dimensions %s, choose one", strings.Join(dims, ", "))
+	}
+	if math.Abs(weight-100) > 0.01 {
+		return nil, fmt.Errorf("Target weights for %s add up to %.2f instead of 100", dims[0], weight)
+	}
+
+	settings := fn.MDB.GetUserSettings(ctx)
+	if band <= 0 {
+		band = settings.DriftBand
+	}
+	if band <= 0 {
+		band = DefaultDriftBand
+	}
+
+	hs, err := fn.InvestmentsHoldings(ctx, "", "", true, "")
+	if err != nil {
+		return nil, err
+	}
+	items, total := fn.allocItems(ctx, hs)
+
+	rb := &store.Rebalance{Dimension: dims[0], DriftBand: band, MktValue: utils.ToFixed(total, 2)}
+	rb.Drifts = []*store.RebalanceDrift{}
+	rb.Trades = []*store.RebalanceTrade{}
+	rb.Warnings = []string{}
+	if total <= 0 {
+		return rb, nil
+	}
+
+	var slices []*rebalanceSlice
+	sm := make(map[string]*rebalanceSlice)
+	for _, target := range targets {
+		slice := &rebalanceSlice{drift: &store.RebalanceDrift{Dimension: target.Dimension, Name: target.Name, Target: target.Weight}}
+		sm[target.Name] = slice
+		slices = append(slices, slice)
+	}
+	for _, item := range items {
+		name := item.names[rb.Dimension]
+		if len(name) == 0 {
+			name = AllocUnclassified
+		}
+		slice := sm[name]
+		if slice == nil {
+			slice = &rebalanceSlice{drift: &store.RebalanceDrift{Dimension: rb.Dimension, Name: name}}
+			sm[name] = slice
+			slices = append(slices, slice)
+		}
+		slice.drift.MktValue += item.mktValue
+		slice.items = append(slice.items, item)
+	}
+
+	for _, slice := range slices {
+		d := slice.drift
+		d.Current = d.MktValue * 100 / total
+		d.Drift = d.Current - d.Target
+		d.TargetValue = total * d.Target / 100
+		if math.Abs(d.Drift) > band {
+			d.OutOfBand = true
+			slice.trade = d.TargetValue - d.MktValue
+		}
+	}
+	balanceSlices(slices)
+
+	lots := fn.getLots(ctx, "", "", "", "", true, true, false)
+	now := time.Now()
+	if err := fn.convertLots(ctx, lots, fn.reportingCurrency(ctx, ""), now); err != nil {
+		return nil, err
+	}
+	lots = fn.taxLots(ctx, lots, TaxReportAll)
+	for _, slice := range slices {
+		d := slice.drift
+		d.Trade = utils.ToFixed(slice.trade, 2)
+		d.Current = utils.ToFixed(d.Current, 2)
+		d.Drift = utils.ToFixed(d.Drift, 2)
+		d.MktValue = utils.ToFixed(d.MktValue, 2)
+		d.TargetValue = utils.ToFixed(d.TargetValue, 2)
+		rb.Drifts = append(rb.Drifts, d)
+
+		if d.Trade < 0 {
+			rb.Trades = append(rb.Trades, rebalanceSales(slice, lots, -slice.trade, now)...)
+		} else if d.Trade > 0 {
+			trade := fn.rebalanceBuy(ctx, slice, slice.trade)
+			if trade == nil {
+				rb.Warnings = append(rb.Warnings, fmt.Sprintf("No holding to buy %.2f of %s %s", d.Trade, d.Dimension, d.Name))
+				continue
+			}
+			if len(trade.Account) == 0 {
+				rb.Warnings = append(rb.Warnings, fmt.Sprintf("No account holds %s, choose the account to buy it in", trade.Symbol))
+			}
+			rb.Trades = append(rb.Trades, trade)
+		}
+	}
+
+	for _, trade := range rb.Trades {
+		rb.ShortTermGain += trade.ShortTermGain
+		rb.LongTermGain += trade.LongTermGain
+		if trade.ShortTermGain > 0 {
+			rb.Warnings = append(rb.Warnings, fmt.Sprintf("Sale of %s in %s realizes a short-term gain of %.2f", trade.Symbol, trade.Account, trade.ShortTermGain))
+		}
+	}
+	rb.ShortTermGain = utils.ToFixed(rb.ShortTermGain, 2)
+	rb.LongTermGain = utils.ToFixed(rb.LongTermGain, 2)
+	return rb, nil
+}
+
+//balanceSlices makes the sales equal to the buys. The difference is taken from the slices within
+//the band moving towards their targets, and the larger side is scaled down when it is not enough.
+func balanceSlices(slices []*rebalanceSlice) {
+
+	sells, buys := 0.0, 0.0
+	for _, slice := range slices {
+		if slice.trade < 0 {
+			sells -= slice.trade
+		} else {
+			buys += slice.trade
+		}
+	}
+	if sells == buys {
+		return
+	}
+
+	//the slices within the band below their targets absorb extra sales, the ones above fund extra buys
+	extra := sells - buys
+	gap := 0.0
+	for _, slice := range slices {
+		if !slice.drift.OutOfBand && (slice.drift.TargetValue-slice.drift.MktValue)*extra > 0 {
+			gap += math.Abs(slice.drift.TargetValue - slice.drift.MktValue)
+		}
+	}
+	if gap > 0 {
+		share := math.Min(math.Abs(extra)/gap, 1)
+		for _, slice := range slices {
+			if diff := slice.drift.TargetValue - slice.drift.MktValue; !slice.drift.OutOfBand && diff*extra > 0 {
+				slice.trade = diff * share
+				if extra > 0 {
+					buys += slice.trade
+				} else {
+					sells -= slice.trade
+				}
+			}
+		}
+	}
+
+	if sells > buys && sells > 0 {
+		scale := buys / sells
+		for _, slice := range slices {
+			if slice.trade < 0 {
+				slice.trade *= scale
+			}
+		}
+	} else if buys > sells && buys > 0 {
+		scale := sells / buys
+		for _, slice := range slices {
+			if slice.trade > 0 {
+				slice.trade *= scale
+			}
+		}
+	}
+}
+
+//rebalanceSales sells the amount from the lots of the slice in the order of their tax cost
+func rebalanceSales(slice *rebalanceSlice, lots store.InvLots, amount float64, date time.Time) []*store.RebalanceTrade {
+
+	held := make(map[string]bool)
+	for _, item := range slice.items {
+		held[item.holding.Account+":"+item.holding.Symbol] = true
+	}
+	var sl store.InvLots
+	for _, lot := range lots {
+		if held[lot.Account+":"+lot.Symbol] && lot.Qty > 0 && lot.PrLast > 0 {
+			sl = append(sl, lot)
+		}
+	}
+	sort.SliceStable(sl, func(i, j int) bool {
+		ri, rj := saleRank(sl[i], date), saleRank(sl[j], date)
+		if ri != rj {
+			return ri < rj
+		}
+		return sl[i].Glperc < sl[j].Glperc
+	})
+
+	var trades []*store.RebalanceTrade
+	tm := make(map[string]*store.RebalanceTrade)
+	for _, lot := range sl {
+		if amount <= 0.005 {
+			break
+		}
+		qty := math.Min(lot.Qty, amount/lot.PrLast)
+		amount -= qty * lot.PrLast
+
+		key := lot.Account + ":" + lot.Symbol
+		trade := tm[key]
+		if trade == nil {
+			trade = &store.RebalanceTrade{Account: lot.Account, TaxTreatment: lot.TaxTreatment, Symbol: lot.Symbol, TxnType: "Sale", Price: lot.PrLast}
+			tm[key] = trade
+			trades = append(trades, trade)
+		}
+		trade.Qty += qty
+		trade.Amount += qty * lot.PrLast
+		if strings.Compare(lot.TaxTreatment, store.TaxTaxable) == 0 {
+			gain := qty * (lot.PrLast - lot.Cost)
+			if longTerm(lot, date) {
+				trade.LongTermGain += gain
+			} else {
+				trade.ShortTermGain += gain
+			}
+		}
+	}
+
+	for _, trade := range trades {
+		trade.Qty = utils.ToFixed(trade.Qty, 6)
+		trade.Amount = utils.ToFixed(trade.Amount, 2)
+		trade.ShortTermGain = utils.ToFixed(trade.ShortTermGain, 2)
+		trade.LongTermGain = utils.ToFixed(trade.LongTermGain, 2)
+	}
+	return trades
+}
+
+//saleRank orders the lots to sell: tax-advantaged, losses, long-term gains, short-term gains
+func saleRank(lot *store.InvLot, date time.Time) int {
+	switch {
+	case strings.Compare(lot.TaxTreatment, store.TaxTaxable) != 0:
+		return 0
+	case lot.PrLast <= lot.Cost:
+		return 1
+	case longTerm(lot, date):
+		return 2
+	}
+	return 3
+}
+
+//rebalanceBuy buys the amount in the largest holding of the slice, or the symbol of the slice when not held
+func (fn *Finance) rebalanceBuy(ctx context.Context, slice *rebalanceSlice, amount float64) *store.RebalanceTrade {
+
+	var largest *store.InvHolding
+	for _, item := range slice.items {
+		if largest == nil || item.holding.MktValue > largest.MktValue {
+			largest = item.holding
+		}
+	}
+
+	trade := &store.RebalanceTrade{TxnType: "Buy"}
+	if largest != nil {
+		trade.Account = largest.Account
+		trade.Symbol = largest.Symbol
+		trade.Price = largest.PrLast
+	} else if strings.Compare(slice.drift.Dimension, AllocSymbol) == 0 {
+		trade.Symbol = slice.drift.Name
+		if ts := fn.MDB.GetTickers(ctx, []string{tickerSymbol(trade.Symbol)}); len(ts) > 0 {
+			if rate, ok := fn.newFXRates(ctx).rate(tickerCurrency(ts[0]), fn.reportingCurrency(ctx, ""), time.Now()); ok {
+				trade.Price = ts[0].PrLast * rate
+			}
+		}
+	}
+	if len(trade.Symbol) == 0 || trade.Price <= 0 {
+		return nil
+	}
+
+	trade.TaxTreatment = store.TaxTaxable
+	if acct := fn.investmentsAccountsMap(ctx)[trade.Account]; acct != nil && len(acct.TaxTreatment) > 0 {
+		trade.TaxTreatment = acct.TaxTreatment
+	}
+	trade.Qty = utils.ToFixed(amount/trade.Price, 6)
+	trade.Amount = utils.ToFixed(amount, 2)
+	return trade
+}
+
+//longTerm returns true if the lot is held for more than a year on the date
+func longTerm(lot *store.InvLot, date time.Time) bool {
+	return lot.Date != nil && date.After(lot.Date.AddDate(1, 0, 0))
+}
@@ -0,0 +1,259 @@
+package core
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+)
+
+const (
+	//CadenceWeekly defines the string Weekly
+	CadenceWeekly string = "Weekly"
+	//CadenceBiweekly defines the string Biweekly
+	CadenceBiweekly string = "Biweekly"
+	//CadenceMonthly defines the string Monthly
+	CadenceMonthly string = "Monthly"
+	//CadenceAnnual defines the string Annual
+	CadenceAnnual string = "Annual"
+)
+
+type cadence struct {
+	name    string
+	days    float64
+	minDays float64
+	maxDays float64
+	count   int
+}
+
+var cadences = []cadence{
+	{CadenceWeekly, 7, 5, 9, 4},
+	{CadenceBiweekly, 14, 12, 16, 3},
+	{CadenceMonthly, 30.4, 26, 35, 3},
+	{CadenceAnnual, 365, 350, 380, 2},
+}
+
+//amount tolerance for charges to be considered the same subscription
+const recurringAmountTolerance = 0.2
+
+//charges closer than this are treated as duplicates
+const recurringDuplicateDays = 3
+
+//TransactionsSubscriptions detects recurring charges from the transactions of the last months
+func (fn *Finance) TransactionsSubscriptions(ctx context.Context, months int, all bool) store.Subscriptions {
+
+	if months <= 0 {
+		months = 24
+	}
+	et := time.Now()
+	ft := et.AddDate(0, -months, 0)
+
+	actvs := fn.MDB.TransactionsActivities(ctx, "debit", &ft, &et)
+	return detectSubscriptions(actvs, et, all)
+}
+
+func detectSubscriptions(actvs store.Activities, now time.Time, all bool) store.Subscriptions {
+
+	//group charges by merchant and amount
+	mm := make(map[string][]store.Activities)
+	var merchants []string
+	for _, actv := range actvs {
+		if actv.Date == nil || actv.Amount == 0 {
+			continue
+		}
+		merchant := utils.NormalizeMerchant(actv.Description)
+		if len(merchant) == 0 {
+			continue
+		}
+
+		clusters, ok := mm[merchant]
+		if !ok {
+			merchants = append(merchants, merchant)
+		}
+
+		found := false
+		for x, cluster := range clusters {
+			last := cluster[len(cluster)-1]
+			if amountClose(last.Amount, actv.Amount, recurringAmountTolerance) {
+				clusters[x] = append(cluster, actv)
+				found = true
+				break
+			}
+		}
+		if !found {
+			clusters = append(clusters, store.Activities{actv})
+		}
+		mm[merchant] = clusters
+	}
+
+	subs := store.Subscriptions{}
+	for _, merchant := range merchants {
+		for _, cluster := range mm[merchant] {
+			sub := detectSubscription(merchant, cluster, now)
+			if sub == nil {
+				continue
+			}
+			if !all && !sub.Active {
+				continue
+			}
+			subs = append(subs, sub)
+		}
+	}
+
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].NextDate.Before(*subs[j].NextDate)
+	})
+	return subs
+}
+
+func detectSubscription(merchant string, charges store.Activities, now time.Time) *store.Subscription {
+
+	if len(charges) < 2 {
+		return nil
+	}
+
+	//separate charges that landed within a few days of the previous one
+	var regular store.Activities
+	var duplicates []*time.Time
+	for _, charge := range charges {
+		if len(regular) > 0 {
+			prev := regular[len(regular)-1]
+			if daysBetween(*prev.Date, *charge.Date) < recurringDuplicateDays {
+				duplicates = append(duplicates, charge.Date)
+				continue
+			}
+		}
+		regular = append(regular, charge)
+	}
+
+	var intervals []float64
+	for x := 1; x < len(regular); x++ {
+		intervals = append(intervals, daysBetween(*regular[x-1].Date, *regular[x].Date))
+	}
+	if len(intervals) == 0 {
+		return nil
+	}
+
+	cd := matchCadence(intervals, len(regular))
+	if cd == nil {
+		return nil
+	}
+
+	sub := &store.Subscription{}
+	sub.Merchant = merchant
+	sub.Cadence = cd.name
+	sub.Count = len(regular)
+	sub.Duplicates = duplicates
+	sub.Charges = charges
+
+	//compare against the last charge billed at a different amount
+	last := regular[len(regular)-1]
+	prev := regular[len(regular)-2]
+	for x := len(regular) - 2; x >= 0; x-- {
+		if regular[x].Amount != last.Amount {
+			prev = regular[x]
+			break
+		}
+	}
+	sub.Group = last.Group
+	sub.Category = last.Category
+	sub.Account = last.Account
+	sub.Amount = last.Amount
+	sub.PrevAmount = prev.Amount
+	sub.PriceChange = utils.ToFixed(last.Amount-prev.Amount, 2)
+	sub.FirstDate = regular[0].Date
+	sub.LastDate = last.Date
+
+	var total float64
+	for _, charge := range regular {
+		total += charge.Amount
+	}
+	sub.AvgAmount = utils.ToFixed(total/float64(len(regular)), 2)
+
+	//gaps of two or more periods mean a charge was missed
+	for x := 1; x < len(regular); x++ {
+		missed := int(math.Round(intervals[x-1]/cd.days)) - 1
+		date := *regular[x-1].Date
+		for m := 0; m < missed; m++ {
+			date = nextCadenceDate(date, cd.name)
+			md := date
+			sub.Missed = append(sub.Missed, &md)
+		}
+	}
+
+	next := nextCadenceDate(*last.Date, cd.name)
+	grace := time.Duration(cd.maxDays-cd.days) * 24 * time.Hour
+	overdue := 0
+	for next.Add(grace).Before(now) && overdue < 2 {
+		md := next
+		sub.Missed = append(sub.Missed, &md)
+		next = nextCadenceDate(next, cd.name)
+		overdue++
+	}
+	sub.NextDate = &next
+
+	//a subscription is considered cancelled once more than one expected charge is missing
+	sub.Active = overdue <= 1
+
+	return sub
+}
+
+//matchCadence returns the cadence most of the intervals fall into
+func matchCadence(intervals []float64, count int) *cadence {
+
+	for x := range cadences {
+		cd := &cadences[x]
+		if count < cd.count {
+			continue
+		}
+		single := 0
+		matched := 0
+		for _, interval := range intervals {
+			//gaps of up to three periods are accepted as missed charges
+			periods := math.Round(interval / cd.days)
+			if periods < 1 || periods > 3 {
+				continue
+			}
+			if interval/periods >= cd.minDays && interval/periods <= cd.maxDays {
+				matched++
+				if periods == 1 {
+					single++
+				}
+			}
+		}
+		//allow the odd irregular interval
+		if single*2 >= len(intervals) && float64(matched) >= math.Ceil(float64(len(intervals))*0.75) {
+			return cd
+		}
+	}
+	return nil
+}
+
+func nextCadenceDate(date time.Time, name string) time.Time {
+	switch name {
+	case CadenceWeekly:
+		return date.AddDate(0, 0, 7)
+	case CadenceBiweekly:
+		return date.AddDate(0, 0, 14)
+	case CadenceMonthly:
+		return date.AddDate(0, 1, 0)
+	case CadenceAnnual:
+		return date.AddDate(1, 0, 0)
+	}
+	return date
+}
+
+func daysBetween(from time.Time, to time.Time) float64 {
+	return math.Abs(to.Sub(from).Hours() / 24)
+}
+
+func amountClose(a float64, b float64, tolerance float64) bool {
+	base := math.Max(math.Abs(a), math.Abs(b))
+	if base == 0 {
+		return true
+	}
+	return math.Abs(a-b)/base <= tolerance
+}
@@ -0,0 +1,212 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+)
+
+//DefaultRiskPeriod is the period of the risk metrics when not set
+const DefaultRiskPeriod string = "1Y"
+
+//riskPoint holds the return between two dates
+type riskPoint struct {
+	from time.Time
+	to   time.Time
+	ret  float64
+}
+
+//InvestmentsRisk returns the risk of the portfolio and its holdings over the period compared with the benchmark.
+//The portfolio returns are from the snapshots, filled from the values replayed from the activities on the days
+//without a snapshot, and exclude the external cash flows. The holding returns are from the close history of the symbols.
+func (fn *Finance) InvestmentsRisk(ctx context.Context, period string, benchmark string, byAcct bool) (*store.InvRisk, error) {
+
+	hs, err := fn.InvestmentsHoldings(ctx, "", "", byAcct, "")
+	if err != nil {
+		return nil, err
+	}
+
+	risk, err := fn.InvestmentsHoldingsRisk(ctx, hs, period, benchmark)
+	if err != nil {
+		return nil, err
+	}
+	risk.Holdings = store.InvHoldings{}
+	for _, h := range hs {
+		if h.Qty > 0 {
+			risk.Holdings = append(risk.Holdings, h)
+		}
+	}
+	return risk, nil
+}
+
+//InvestmentsHoldingsRisk sets the risk metrics of the holdings and returns the risk of the portfolio
+func (fn *Finance) InvestmentsHoldingsRisk(ctx context.Context, hs store.InvHoldings, period string, benchmark string) (*store.InvRisk, error) {
+
+	if len(period) == 0 {
+		period = DefaultRiskPeriod
+	}
+	pdate := utils.DateForPeriod(period)
+	if pdate == nil {
+		return nil, fmt.Errorf("Period %s is not valid", period)
+	}
+	start := perfDate(*pdate)
+
+	settings := fn.MDB.GetUserSettings(ctx)
+	benchmark = strings.ToUpper(strings.TrimSpace(benchmark))
+	if len(benchmark) == 0 && len(settings.Benchmarks) > 0 {
+		benchmark = strings.ToUpper(settings.Benchmarks[0])
+	}
+	if len(benchmark) == 0 {
+		benchmark = DefaultBenchmarks[0]
+	}
+
+	risk := &store.InvRisk{Period: period, Benchmark: benchmark, RiskFreeRate: settings.RiskFreeRate}
+	pd, err := fn.perfData(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pd == nil {
+		risk.Portfolio = &store.RiskMetrics{Period: period, Benchmark: benchmark}
+		return risk, nil
+	}
+	fn.loadPrices(ctx, pd, []string{benchmark})
+
+	days := pd.scopeDays(perfScope{level: PerfPortfolio})
+	pd.snapshotDays(days, fn.MDB.Snapshots(ctx, &start, nil, false))
+	risk.Portfolio = pd.riskMetrics(portfolioPoints(days, start), benchmark, settings.RiskFreeRate)
+	risk.Portfolio.Period = period
+
+	rm := make(map[string]*store.RiskMetrics)
+	for _, h := range hs {
+		symbol := tickerSymbol(h.Symbol)
+		if rm[symbol] == nil {
+			rm[symbol] = pd.riskMetrics(pd.pricePoints(symbol, start), benchmark, settings.RiskFreeRate)
+			rm[symbol].Period = period
+		}
+		h.Risk = rm[symbol]
+	}
+	return risk, nil
+}
+
+//snapshotDays replaces the values of the days with the values of the snapshots of the same days
+//converted to the currency of the data. Snapshots without a rate keep the replayed value.
+func (pd *perfData) snapshotDays(days []perfDay, snaps store.Snapshots) {
+
+	for _, snap := range snaps {
+		date := perfDate(*snap.Date)
+		x := sort.Search(len(days), func(i int) bool {
+			return !days[i].date.Before(date)
+		})
+		if x == len(days) || !days[x].date.Equal(date) {
+			continue
+		}
+		if rate, ok := pd.fx.rate(balanceCurrency(snap.Currency), pd.currency, date); ok {
+			days[x].value = snap.MktValue * rate
+		}
+	}
+}
+
+//portfolioPoints returns the daily returns of the portfolio from the start date excluding the cash flows
+func portfolioPoints(days []perfDay, start time.Time) []riskPoint {
+
+	var rps []riskPoint
+	for x := 1; x < len(days); x++ {
+		if days[x].date.Before(start) {
+			continue
+		}
+		prev := days[x-1]
+		if den := prev.value + days[x].in; den > 0 {
+			rps = append(rps, riskPoint{prev.date, days[x].date, (days[x].value+days[x].out)/den - 1})
+		}
+	}
+	return rps
+}
+
+//pricePoints returns the returns between the consecutive prices of the symbol from the start date
+func (pd *perfData) pricePoints(symbol string, start time.Time) []riskPoint {
+
+	var rps []riskPoint
+	var prev *pricePoint
+	pps := pd.prices[symbol]
+	for x := range pps {
+		pp := &pps[x]
+		//the last price of the day is used when the history and the trades share a day
+		if x+1 < len(pps) && pps[x+1].date.Equal(pp.date) {
+			continue
+		}
+		if pp.date.Before(start) || pp.price <= 0 {
+			continue
+		}
+		if prev != nil {
+			rps = append(rps, riskPoint{prev.date, pp.date, pp.price/prev.price - 1})
+		}
+		prev = pp
+	}
+	return rps
+}
+
+//riskMetrics returns the risk of the returns. Volatility, Sharpe and Sortino are annualized over
+//the number of returns per year, so trading days for stocks and calendar days for crypto.
+func (pd *perfData) riskMetrics(rps []riskPoint, benchmark string, riskFreeRate float64) *store.RiskMetrics {
+
+	rm := &store.RiskMetrics{Benchmark: benchmark, Days: len(rps)}
+	if len(rps) < 2 {
+		return rm
+	}
+	years := rps[len(rps)-1].to.Sub(rps[0].from).Hours() / 24 / 365
+	if years <= 0 {
+		return rm
+	}
+	n := float64(len(rps)) / years
+	rf := riskFreeRate / 100 / n
+
+	var rets, brets []float64
+	var downside float64
+	index, peak := 1.0, 1.0
+	peakDate := rps[0].from
+	for _, rp := range rps {
+		rets = append(rets, rp.ret)
+		if b0 := pd.price(benchmark, rp.from); b0 > 0 {
+			brets = append(brets, pd.price(benchmark, rp.to)/b0-1)
+		} else {
+			brets = append(brets, 0)
+		}
+		if rp.ret < rf {
+			downside += (rp.ret - rf) * (rp.ret - rf)
+		}
+
+		index *= 1 + rp.ret
+		if index >= peak {
+			peak = index
+			peakDate = rp.to
+			continue
+		}
+		if dd := (index/peak - 1) * 100; dd < rm.MaxDrawdown {
+			rm.MaxDrawdown = dd
+		}
+		if days := int(rp.to.Sub(peakDate).Hours() / 24); days > rm.DrawdownDays {
+			rm.DrawdownDays = days
+		}
+	}
+
+	mean := utils.Mean(rets)
+	sd := utils.StdDev(rets)
+	rm.Volatility = utils.ToFixed(sd*math.Sqrt(n)*100, 2)
+	rm.MaxDrawdown = utils.ToFixed(rm.MaxDrawdown, 2)
+	if sd > 0 {
+		rm.Sharpe = utils.ToFixed((mean-rf)/sd*math.Sqrt(n), 2)
+	}
+	if downside > 0 {
+		rm.Sortino = utils.ToFixed((mean-rf)/math.Sqrt(downside/float64(len(rets)))*math.Sqrt(n), 2)
+	}
+	if variance := utils.Covariance(brets, brets); variance > 0 {
+		rm.Beta = utils.ToFixed(utils.Covariance(rets, brets)/variance, 2)
+	}
+	return rm
+}
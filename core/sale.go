@@ -0,0 +1,112 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+)
+
+//InvestmentsSaleProjection simulates the sale of the quantity of the symbol in the account at the price, or the last price
+//when not set. The open lots are relieved in the order of the cost basis of the account as the import would, without
+//saving them. The tax is estimated at the rates, or the rates in the user settings when not set, and is negative for losses.
+//Sales in tax-advantaged accounts are not taxed.
+func (fn *Finance) InvestmentsSaleProjection(ctx context.Context, account string, symbol string, qty float64, price float64, stRate float64, ltRate float64) (*store.SaleProjection, error) {
+
+	if len(account) == 0 || len(symbol) == 0 {
+		return nil, fmt.Errorf("Account and symbol are required")
+	}
+	if qty <= 0 {
+		return nil, fmt.Errorf("Quantity must be greater than 0")
+	}
+
+	settings := fn.MDB.GetUserSettings(ctx)
+	if stRate <= 0 {
+		stRate = settings.ShortTermTaxRate
+	}
+	if stRate <= 0 {
+		stRate = DefaultShortTermTaxRate
+	}
+	if ltRate <= 0 {
+		ltRate = settings.LongTermTaxRate
+	}
+	if ltRate <= 0 {
+		ltRate = DefaultLongTermTaxRate
+	}
+
+	now := time.Now()
+	acct := fn.investmentsAccountsMap(ctx)[account]
+	asc, hifo := lotOrder(acct, now)
+	lots := fn.getLots(ctx, "", "", account, symbol, true, asc, hifo)
+
+	held := 0.0
+	before := make(map[*store.InvLot]float64)
+	for _, lot := range lots {
+		held += lot.Qty
+		before[lot] = lot.Qty
+		if price <= 0 {
+			price = lot.PrLast
+		}
+	}
+	if held+1e-9 < qty {
+		return nil, fmt.Errorf("Only %v of %s is held in %s", held, symbol, account)
+	}
+	if price <= 0 {
+		return nil, fmt.Errorf("No price for %s", symbol)
+	}
+
+	sp := &store.SaleProjection{Account: account, Symbol: symbol, Qty: qty, Price: price}
+	sp.TaxTreatment = store.TaxTaxable
+	if acct != nil && len(acct.TaxTreatment) > 0 {
+		sp.TaxTreatment = acct.TaxTreatment
+	}
+	sp.CostBasis = store.BasisFIFO
+	if hifo {
+		sp.CostBasis = store.BasisHIFO
+	} else if !asc {
+		sp.CostBasis = store.BasisLIFO
+	}
+	sp.ShortTermTaxRate = stRate
+	sp.LongTermTaxRate = ltRate
+
+	actv := &store.Activity{Account: account, Symbol: symbol, TxnType: "Sale", Qty: qty, Price: price, Date: &now}
+	relieveLots(actv, lots)
+
+	sp.Lots = []*store.SaleLot{}
+	for _, lot := range lots {
+		sqty := before[lot] - lot.Qty
+		if sqty <= 0 {
+			continue
+		}
+		sl := &store.SaleLot{Date: lot.Date, Qty: sqty, Cost: lot.Cost}
+		sl.CostValue = sqty * lot.Cost
+		sl.SaleValue = sqty * price
+		sl.Gain = sl.SaleValue - sl.CostValue
+		sl.Term = TermShort
+		if longTerm(lot, now) {
+			sl.Term = TermLong
+			sp.LongTermGain += sl.Gain
+		} else {
+			sp.ShortTermGain += sl.Gain
+		}
+		sp.Proceeds += sl.SaleValue
+		sp.CostValue += sl.CostValue
+
+		sl.CostValue = utils.ToFixed(sl.CostValue, 2)
+		sl.SaleValue = utils.ToFixed(sl.SaleValue, 2)
+		sl.Gain = utils.ToFixed(sl.Gain, 2)
+		sp.Lots = append(sp.Lots, sl)
+	}
+
+	if strings.Compare(sp.TaxTreatment, store.TaxTaxable) == 0 {
+		sp.Tax = utils.ToFixed((sp.ShortTermGain*stRate+sp.LongTermGain*ltRate)/100, 2)
+	}
+	sp.Proceeds = utils.ToFixed(sp.Proceeds, 2)
+	sp.CostValue = utils.ToFixed(sp.CostValue, 2)
+	sp.ShortTermGain = utils.ToFixed(sp.ShortTermGain, 2)
+	sp.LongTermGain = utils.ToFixed(sp.LongTermGain, 2)
+	return sp, nil
+}
@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+)
+
+//SnapshotHoldings saves the holdings of every user with investments at today's prices.
+//Snapshots are valued in USD whatever the reporting currency, so the days can be compared and converted.
+func (fn *Finance) SnapshotHoldings(ctx context.Context) {
+
+	uids, err := fn.MDB.InvestmentsUsers(ctx)
+	if err != nil {
+		log.Printf("Snapshot users error: %v", err)
+		return
+	}
+
+	for _, uid := range uids {
+		uctx := context.WithValue(ctx, store.UserContextUID, store.User{UID: uid})
+		if err := fn.snapshotHoldings(uctx); err != nil {
+			log.Printf("Snapshot error - user: %s error: %v", uid, err)
+		}
+	}
+	log.Printf("Snapshots - users: %d", len(uids))
+}
+
+func (fn *Finance) snapshotHoldings(ctx context.Context) error {
+
+	hs, err := fn.InvestmentsHoldings(ctx, "", "", true, CurrencyUSD)
+	if err != nil {
+		return err
+	}
+
+	date := marketDate(time.Now())
+	snap := &store.Snapshot{Date: &date, Currency: CurrencyUSD}
+	snap.Holdings = []*store.SnapshotHolding{}
+	for _, h := range hs {
+		if h.Qty <= 0 {
+			continue
+		}
+		sh := &store.SnapshotHolding{Group: h.Group, Category: h.Category, Account: h.Account, Symbol: h.Symbol}
+		sh.Qty = h.Qty
+		sh.Price = h.PrLast
+		sh.MktValue = utils.ToFixed(h.MktValue, 2)
+		sh.CostValue = utils.ToFixed(h.CostValue, 2)
+		snap.MktValue += h.MktValue
+		snap.CostValue += h.CostValue
+		snap.Holdings = append(snap.Holdings, sh)
+	}
+	snap.MktValue = utils.ToFixed(snap.MktValue, 2)
+	snap.CostValue = utils.ToFixed(snap.CostValue, 2)
+	return fn.MDB.SnapshotUpdate(ctx, snap)
+}
+
+//marketLocation is the time zone of the EOD updates
+var marketLocation = loadLocation("America/New_York")
+
+func loadLocation(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("Location %s error: %v", name, err)
+		return time.UTC
+	}
+	return loc
+}
+
+//marketDate returns the day of the time in New York, so the EOD updates after midnight UTC keep the day of the close
+func marketDate(t time.Time) time.Time {
+	t = t.In(marketLocation)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+//InvestmentsSnapshots returns the daily value of the holdings for the date range, with the holdings when holdings is set
+func (fn *Finance) InvestmentsSnapshots(ctx context.Context, fromDate *time.Time, toDate *time.Time, holdings bool) store.Snapshots {
+	snaps := fn.MDB.Snapshots(ctx, fromDate, toDate, holdings)
+	if snaps == nil {
+		snaps = store.Snapshots{}
+	}
+	return snaps
+}
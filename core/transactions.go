@@ -2,11 +2,181 @@ package core
 
 import (
 	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/rkapps/go_finance/store"
+	"github.com/rkapps/go_finance/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-func (fn *Finance) AggregateTransactions(ctx context.Context, fromDate *time.Time, toDate *time.Time) ([]store.TransactionAgg, error) {
-	return fn.MDB.AggregateTransactions(ctx, fromDate, toDate)
+const (
+	//CashFlowIncome defines the string income
+	CashFlowIncome string = "income"
+	//CashFlowExpense defines the string expense
+	CashFlowExpense string = "expense"
+	//CashFlowTransfer defines the string transfer
+	CashFlowTransfer string = "transfer"
+)
+
+func (fn *Finance) AggregateTransactions(ctx context.Context, dbcr string, fromDate *time.Time, toDate *time.Time) ([]store.TransactionAgg, error) {
+	return fn.MDB.AggregateTransactions(ctx, dbcr, false, fromDate, toDate)
+}
+
+//TransactionsCashFlow returns the monthly income, expenses and savings for the year compared with the previous year
+func (fn *Finance) TransactionsCashFlow(ctx context.Context, year int) (*store.CashFlowStatement, error) {
+
+	ft := time.Date(year-1, time.Month(1), 1, 0, 0, 0, 0, time.Now().Location())
+	et := time.Date(year, time.Month(12), 31, 24, 0, 0, 0, time.Now().Location())
+
+	taggs, err := fn.MDB.AggregateTransactions(ctx, "", true, &ft, &et)
+	if err != nil {
+		return nil, err
+	}
+
+	cfs := &store.CashFlowStatement{}
+	cfs.Year = int32(year)
+	cfs.Total = &store.CashFlow{Year: int32(year)}
+	for month := 1; month <= 12; month++ {
+		cfs.Months = append(cfs.Months, &store.CashFlow{Year: int32(year), Month: int32(month)})
+	}
+
+	//groups are keyed by month so the previous year lands on the same entry
+	gm := make(map[string]*store.CashFlowGroup)
+	tgm := make(map[string]*store.CashFlowGroup)
+
+	for _, tagg := range taggs {
+
+		if tagg.Month < 1 || tagg.Month > 12 {
+			continue
+		}
+		prev := tagg.Year != int32(year)
+		cf := cfs.Months[tagg.Month-1]
+
+		ctype, amount := cashFlowType(tagg)
+		for _, c := range []*store.CashFlow{cf, cfs.Total} {
+			switch ctype {
+			case CashFlowIncome:
+				if prev {
+					c.PrevIncome += amount
+				} else {
+					c.Income += amount
+				}
+			case CashFlowExpense:
+				if prev {
+					c.PrevExpenses += amount
+				} else {
+					c.Expenses += amount
+				}
+			case CashFlowTransfer:
+				if !prev {
+					c.Transfers += amount
+				}
+			}
+		}
+
+		key := ctype + ":" + tagg.Group + ":" + tagg.Category
+		addCashFlowGroup(cf, gm, strconv.Itoa(int(tagg.Month))+":"+key, ctype, tagg, amount, prev)
+		addCashFlowGroup(cfs.Total, tgm, key, ctype, tagg, amount, prev)
+	}
+
+	for _, cf := range append(cfs.Months, cfs.Total) {
+		setCashFlowTotals(cf)
+	}
+
+	return cfs, nil
+}
+
+//cashFlowType classifies the aggregate, credits outside of the income group are refunds that reduce expenses.
+//Transfers are reported by the amount leaving the accounts.
+func cashFlowType(tagg store.TransactionAgg) (string, float64) {
+
+	credit := strings.Compare(tagg.Dbcr, "credit") == 0
+	if strings.Compare(tagg.Group, store.GroupTransfer) == 0 ||
+		strings.Compare(tagg.Transfer, store.TransferMatched) == 0 ||
+		strings.Compare(tagg.Transfer, store.TransferConfirmed) == 0 {
+		if credit {
+			return CashFlowTransfer, 0
+		}
+		return CashFlowTransfer, tagg.Amount
+	}
+	if strings.Compare(tagg.Group, store.GroupIncome) == 0 {
+		if credit {
+			return CashFlowIncome, tagg.Amount
+		}
+		return CashFlowIncome, -tagg.Amount
+	}
+	if credit {
+		return CashFlowExpense, -tagg.Amount
+	}
+	return CashFlowExpense, tagg.Amount
+}
+
+func addCashFlowGroup(cf *store.CashFlow, gm map[string]*store.CashFlowGroup, key string, ctype string, tagg store.TransactionAgg, amount float64, prev bool) {
+
+	g := gm[key]
+	if g == nil {
+		g = &store.CashFlowGroup{Type: ctype, Group: tagg.Group, Category: tagg.Category}
+		gm[key] = g
+		cf.Groups = append(cf.Groups, g)
+	}
+	if prev {
+		g.PrevAmount += amount
+	} else {
+		g.Amount += amount
+	}
+}
+
+func setCashFlowTotals(cf *store.CashFlow) {
+
+	cf.Income = utils.ToFixed(cf.Income, 2)
+	cf.Expenses = utils.ToFixed(cf.Expenses, 2)
+	cf.Transfers = utils.ToFixed(cf.Transfers, 2)
+	cf.PrevIncome = utils.ToFixed(cf.PrevIncome, 2)
+	cf.PrevExpenses = utils.ToFixed(cf.PrevExpenses, 2)
+
+	cf.Net = utils.ToFixed(cf.Income-cf.Expenses, 2)
+	cf.PrevNet = utils.ToFixed(cf.PrevIncome-cf.PrevExpenses, 2)
+	if cf.Income > 0 {
+		cf.SavingsRate = utils.ToFixed(cf.Net*100/cf.Income, 2)
+	}
+	if cf.PrevIncome > 0 {
+		cf.PrevSavingsRate = utils.ToFixed(cf.PrevNet*100/cf.PrevIncome, 2)
+	}
+
+	for _, g := range cf.Groups {
+		g.Amount = utils.ToFixed(g.Amount, 2)
+		g.PrevAmount = utils.ToFixed(g.PrevAmount, 2)
+	}
+	order := map[string]int{CashFlowIncome: 0, CashFlowExpense: 1, CashFlowTransfer: 2}
+	sort.SliceStable(cf.Groups, func(i, j int) bool {
+		if cf.Groups[i].Type != cf.Groups[j].Type {
+			return order[cf.Groups[i].Type] < order[cf.Groups[j].Type]
+		}
+		return cf.Groups[i].Amount > cf.Groups[j].Amount
+	})
+}
+
+//ActivitySplitsUpdate replaces the split lines of the transaction, an empty list removes them
+func (fn *Finance) ActivitySplitsUpdate(ctx context.Context, id primitive.ObjectID, splits []*store.ActivitySplit) (*store.Activity, error) {
+
+	actv := fn.MDB.GetActivity(ctx, id)
+	if actv == nil {
+		return nil, errors.New("Activity not found")
+	}
+	if strings.Compare(actv.ActyType, "Transaction") != 0 {
+		return nil, errors.New("Only transactions can be split")
+	}
+
+	actv.Splits = splits
+	err := actv.ValidateSplits()
+	if err != nil {
+		return nil, err
+	}
+
+	err = fn.MDB.ActivitySplitsUpdate(ctx, id, splits)
+	return actv, err
 }
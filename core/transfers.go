@@ -0,0 +1,224 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+//TransferDays is the default number of days allowed between the two sides of a transfer
+const TransferDays = 5
+
+type transferPair struct {
+	debit  *store.Activity
+	credit *store.Activity
+	both   bool
+	days   float64
+}
+
+//TransactionsMatchTransfers pairs outgoing and incoming transactions across accounts for the date range.
+//Transfers without a matching transaction are flagged for review and returned.
+func (fn *Finance) TransactionsMatchTransfers(ctx context.Context, fromDate time.Time, toDate time.Time, days int) (store.Activities, error) {
+
+	if days <= 0 {
+		days = TransferDays
+	}
+	ft := fromDate.AddDate(0, 0, -days)
+	et := toDate.AddDate(0, 0, days+1)
+
+	actvs := fn.MDB.TransactionsActivities(ctx, "", &ft, &et)
+	am := make(map[primitive.ObjectID]*store.Activity)
+	for _, actv := range actvs {
+		am[actv.ID] = actv
+	}
+
+	//transactions on the edges keep their match when the other side was not loaded but still exists
+	var debits, credits, cands store.Activities
+	for _, actv := range actvs {
+		if strings.Compare(actv.Transfer, store.TransferConfirmed) == 0 {
+			continue
+		}
+		if strings.Compare(actv.Transfer, store.TransferMatched) == 0 && am[actv.TransferID] == nil &&
+			fn.MDB.GetActivity(ctx, actv.TransferID) != nil {
+			continue
+		}
+		actv.Transfer = ""
+		actv.TransferID = primitive.NilObjectID
+		cands = append(cands, actv)
+		if strings.Compare(actv.Dbcr, "credit") == 0 {
+			credits = append(credits, actv)
+		} else {
+			debits = append(debits, actv)
+		}
+	}
+
+	var pairs []*transferPair
+	for _, debit := range debits {
+		for _, credit := range credits {
+			if !isTransferPair(debit, credit, days) {
+				continue
+			}
+			pair := &transferPair{debit: debit, credit: credit}
+			pair.both = isTransfer(debit) && isTransfer(credit)
+			pair.days = daysBetween(*debit.Date, *credit.Date)
+			pairs = append(pairs, pair)
+		}
+	}
+
+	//pairs categorized as transfers on both sides and closest in date win
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if pairs[i].both != pairs[j].both {
+			return pairs[i].both
+		}
+		return pairs[i].days < pairs[j].days
+	})
+
+	for _, pair := range pairs {
+		if len(pair.debit.Transfer) > 0 || len(pair.credit.Transfer) > 0 {
+			continue
+		}
+		pair.debit.Transfer = store.TransferMatched
+		pair.debit.TransferID = pair.credit.ID
+		pair.credit.Transfer = store.TransferMatched
+		pair.credit.TransferID = pair.debit.ID
+	}
+
+	unmatched := store.Activities{}
+	for _, actv := range cands {
+		if len(actv.Transfer) == 0 && isTransfer(actv) {
+			actv.Transfer = store.TransferUnmatched
+			unmatched = append(unmatched, actv)
+		}
+	}
+
+	log.Printf("Transfers - transactions: %d pairs: %d unmatched: %d", len(cands), len(pairs), len(unmatched))
+	err := fn.MDB.ActivitiesTransferUpdate(ctx, cands)
+	return unmatched, err
+}
+
+//TransactionsTransfers returns the transactions with the transfer status
+func (fn *Finance) TransactionsTransfers(ctx context.Context, status string) store.Activities {
+	actvs := fn.MDB.TransactionsTransfers(ctx, status)
+	if actvs == nil {
+		actvs = store.Activities{}
+	}
+	return actvs
+}
+
+//TransactionsTransferResolve pairs the transaction with pairID, or confirms it as a transfer when pairID is not set
+func (fn *Finance) TransactionsTransferResolve(ctx context.Context, id primitive.ObjectID, pairID primitive.ObjectID) error {
+
+	actv := fn.MDB.GetActivity(ctx, id)
+	if actv == nil {
+		return errors.New("Activity not found")
+	}
+
+	uactvs := store.Activities{actv}
+	if pairID.IsZero() {
+		uactvs = append(uactvs, fn.unpairTransfer(ctx, actv)...)
+		actv.Transfer = store.TransferConfirmed
+		actv.TransferID = primitive.NilObjectID
+		return fn.MDB.ActivitiesTransferUpdate(ctx, uactvs)
+	}
+
+	pair := fn.MDB.GetActivity(ctx, pairID)
+	if pair == nil {
+		return errors.New("Matching activity not found")
+	}
+	if strings.Compare(actv.Dbcr, pair.Dbcr) == 0 {
+		return errors.New("Transfer must pair a debit with a credit")
+	}
+	if math.Abs(actv.Amount-pair.Amount) >= 0.005 {
+		return errors.New("Transfer amounts do not match")
+	}
+
+	uactvs = append(uactvs, pair)
+	uactvs = append(uactvs, fn.unpairTransfer(ctx, actv)...)
+	uactvs = append(uactvs, fn.unpairTransfer(ctx, pair)...)
+	actv.Transfer = store.TransferMatched
+	actv.TransferID = pair.ID
+	pair.Transfer = store.TransferMatched
+	pair.TransferID = actv.ID
+	return fn.MDB.ActivitiesTransferUpdate(ctx, uactvs)
+}
+
+//releaseTransfers unpairs the transfers matched with the transactions of the group and category in the date range,
+//which are about to be deleted, and returns the released transactions on the other side
+func (fn *Finance) releaseTransfers(ctx context.Context, group string, category string, fromDate *time.Time, toDate *time.Time) store.Activities {
+
+	var ft, tt *time.Time
+	if fromDate != nil && !fromDate.IsZero() {
+		ft = fromDate
+	}
+	if toDate != nil && !toDate.IsZero() {
+		tt = toDate
+	}
+
+	deleted := make(map[primitive.ObjectID]bool)
+	var matched store.Activities
+	for _, actv := range fn.MDB.TransactionsActivities(ctx, "", ft, tt) {
+		if (len(group) > 0 && strings.Compare(actv.Group, group) != 0) ||
+			(len(category) > 0 && strings.Compare(actv.Category, category) != 0) {
+			continue
+		}
+		deleted[actv.ID] = true
+		if strings.Compare(actv.Transfer, store.TransferMatched) == 0 {
+			matched = append(matched, actv)
+		}
+	}
+
+	var released store.Activities
+	for _, actv := range matched {
+		if !deleted[actv.TransferID] {
+			released = append(released, fn.unpairTransfer(ctx, actv)...)
+		}
+	}
+	if err := fn.MDB.ActivitiesTransferUpdate(ctx, released); err != nil {
+		log.Printf("Release transfers error: %v", err)
+	}
+	return released
+}
+
+//unpairTransfer releases the activity previously matched with actv
+func (fn *Finance) unpairTransfer(ctx context.Context, actv *store.Activity) store.Activities {
+
+	if actv.TransferID.IsZero() {
+		return nil
+	}
+	prev := fn.MDB.GetActivity(ctx, actv.TransferID)
+	if prev == nil || prev.TransferID != actv.ID {
+		return nil
+	}
+	prev.Transfer = ""
+	prev.TransferID = primitive.NilObjectID
+	if isTransfer(prev) {
+		prev.Transfer = store.TransferUnmatched
+	}
+	return store.Activities{prev}
+}
+
+//isTransferPair returns true if the debit and credit can be the two sides of a transfer
+func isTransferPair(debit *store.Activity, credit *store.Activity, days int) bool {
+
+	if strings.Compare(debit.Account, credit.Account) == 0 {
+		return false
+	}
+	if !isTransfer(debit) && !isTransfer(credit) {
+		return false
+	}
+	if math.Abs(debit.Amount-credit.Amount) >= 0.005 {
+		return false
+	}
+	return daysBetween(*debit.Date, *credit.Date) <= float64(days)
+}
+
+func isTransfer(actv *store.Activity) bool {
+	return strings.Compare(actv.Group, store.GroupTransfer) == 0
+}
@@ -1,15 +1,12 @@
 package providers
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rkapps/go_finance/store"
 	"github.com/rkapps/go_finance/utils"
@@ -30,12 +27,32 @@ type hResponse struct {
 
 type aResponse struct {
 	Res map[string]hResponse `json:"Time Series (Daily)"`
+	alphaMessage
+}
+
+//alphaMessage holds the messages AlphaVantage returns instead of the data
+type alphaMessage struct {
+	Note         string `json:"Note"`
+	Information  string `json:"Information"`
+	ErrorMessage string `json:"Error Message"`
+}
+
+//err returns the error of a response carrying a message. The calls over the rate limit are answered with a note.
+func (am alphaMessage) err(symbol string) error {
+	if len(am.Note) > 0 || len(am.Information) > 0 {
+		return &RateLimitError{Provider: ProviderAlpha, Message: am.Note + am.Information}
+	}
+	if len(am.ErrorMessage) > 0 {
+		return fmt.Errorf("%s %s: %w", ProviderAlpha, symbol, ErrNotFound)
+	}
+	return nil
 }
 
 type TickerOResponse struct {
 	Exchange  string `json:"Exchange"`
 	Symbol    string `json:"Symbol"`
 	Name      string `json:"Name"`
+	AssetType string `json:"AssetType"`
 	Overview  string `json:"Description"`
 	Sector    string `json:"Sector"`
 	Industry  string `json:"Industry"`
@@ -50,30 +67,75 @@ type TickerOResponse struct {
 	ExDivDate string `json:"ExDividendDate"`
 	PayDate   string `json:"DividendDate"`
 	PayRatio  string `json:"PayoutRatio"`
+	alphaMessage
 }
 
-//GetTickerQuotes returns LastPrice and PrevPrice for the ticker
-func GetTickerQuotes(tickers store.Tickers) (map[string]*store.TickerHistory, map[string]*store.TickerHistory) {
+//Alpha provides the daily history, quotes and fundamentals from the AlphaVantage API
+type Alpha struct{}
+
+//Name returns the name of the provider
+func (Alpha) Name() string {
+	return ProviderAlpha
+}
 
-	thm := make(map[string]*store.TickerHistory)
-	pthm := make(map[string]*store.TickerHistory)
+//History returns the daily history of the tickers for the date range
+func (Alpha) History(tickers store.Tickers, st time.Time, et time.Time) (map[string][]*store.TickerHistory, error) {
 
+	var err error
+	thm := make(map[string][]*store.TickerHistory)
 	for _, ticker := range tickers {
-		if ticker.IsIndex() {
-			thm1 := getTickerQuoteFromAlpha(*ticker, "compact")
-			if len(thm1) > 0 {
-				thm[ticker.GetTickerID()] = thm1[len(thm1)-1]
-				if len(thm1) > 1 {
-					pthm[ticker.GetTickerID()] = thm1[len(thm1)-2]
-				}
+		ths, aerr := getTickerQuoteFromAlpha(*ticker, "full")
+		if errors.Is(aerr, ErrRateLimited) {
+			return thm, aerr
+		}
+		if aerr != nil {
+			err = aerr
+			continue
+		}
+		var rths []*store.TickerHistory
+		for _, th := range ths {
+			if !th.Date.Before(st) && !th.Date.After(et) {
+				rths = append(rths, th)
 			}
 		}
+		thm[ticker.GetTickerID()] = rths
 	}
+	return thm, err
+}
+
+//Quotes returns the last and previous daily close of the tickers
+func (Alpha) Quotes(tickers store.Tickers) (map[string]*Quote, error) {
 
-	return thm, pthm
+	var err error
+	qm := make(map[string]*Quote)
+	for _, ticker := range tickers {
+		ths, aerr := getTickerQuoteFromAlpha(*ticker, "compact")
+		if errors.Is(aerr, ErrRateLimited) {
+			return qm, aerr
+		}
+		if aerr != nil {
+			err = aerr
+			continue
+		}
+		if len(ths) > 0 {
+			th := ths[len(ths)-1]
+			q := &Quote{Price: th.Close, Date: &th.Date}
+			if len(ths) > 1 {
+				q.Prev = ths[len(ths)-2].Close
+			}
+			qm[ticker.GetTickerID()] = q
+		}
+	}
+	return qm, err
 }
 
-func getTickerQuoteFromAlpha(ticker store.Ticker, output string) []*store.TickerHistory {
+//Fundamentals sets the overview, ratios and dividends of the ticker
+func (Alpha) Fundamentals(ticker *store.Ticker) error {
+	_, err := UpdateTickerDetails(ticker)
+	return err
+}
+
+func getTickerQuoteFromAlpha(ticker store.Ticker, output string) ([]*store.TickerHistory, error) {
 
 	var ths []*store.TickerHistory
 
@@ -82,24 +144,13 @@ func getTickerQuoteFromAlpha(ticker store.Ticker, output string) []*store.Ticker
 	api := fmt.Sprintf("&apikey=%s", alphaAPIKey)
 	url := strings.Join([]string{alphaURL, "function=TIME_SERIES_DAILY", symbol, size, api}, "")
 	// log.Printf("url: %s", url)
-	resp, err := http.Get(url)
-	if err != nil {
-		log.Print(err)
-		return ths
-	}
-
-	defer resp.Body.Close()
-
-	body, _ := ioutil.ReadAll(resp.Body)
 	var s = new(aResponse)
 	s.Res = make(map[string]hResponse)
-	err1 := json.Unmarshal(body, &s)
-
-	if err1 != nil {
-		//if the url does not return any data, just return
-		log.Print(err1)
-		// panic(err1)
-		return ths
+	if err := runHTTPGet(ProviderAlpha, url, &s); err != nil {
+		return ths, err
+	}
+	if err := s.err(ticker.Symbol); err != nil {
+		return ths, err
 	}
 
 	//sort of date keys
@@ -122,7 +173,7 @@ func getTickerQuoteFromAlpha(ticker store.Ticker, output string) []*store.Ticker
 		ths = append(ths, &th)
 		// th.Volume, _ = strconv.Atoi(h.Volume)
 	}
-	return ths
+	return ths, nil
 }
 
 func UpdateTickerDetails(ticker *store.Ticker) (string, error) {
@@ -130,12 +181,15 @@ func UpdateTickerDetails(ticker *store.Ticker) (string, error) {
 	var or TickerOResponse
 	url := fmt.Sprintf("%sfunction=%s&symbol=%s&apikey=%s", alphaURL, "OVERVIEW", ticker.Symbol, alphaAPIKey)
 	// log.Println(url)
-	err := runHTTPGet(url, &or)
+	err := runHTTPGet(ProviderAlpha, url, &or)
 	if err != nil {
 		return url, err
 	}
+	if err := or.err(ticker.Symbol); err != nil {
+		return url, err
+	}
 	if len(or.Symbol) == 0 || len(or.Name) == 0 {
-		return url, errors.New("Symbol is blank")
+		return url, fmt.Errorf("Symbol is blank: %w", ErrNotFound)
 	}
 	if len(or.Exchange) == 0 {
 		return url, errors.New("Exchange is blank")
@@ -196,6 +250,9 @@ func UpdateTickerDetails(ticker *store.Ticker) (string, error) {
 	var imcap, _ = strconv.Atoi(or.MktCap)
 
 	ticker.Overview = or.Overview
+	if len(or.AssetType) > 0 {
+		ticker.AssetType = or.AssetType
+	}
 	ticker.MarketCap = imcap
 	ticker.EPS, _ = strconv.ParseFloat(or.EPS, 64)
 	ticker.PBRatio, _ = strconv.ParseFloat(or.PBRatio, 64)
@@ -1,9 +1,13 @@
 package providers
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
 	"strconv"
 	"strings"
+
+	"github.com/rkapps/go_finance/store"
 )
 
 const (
@@ -21,12 +25,19 @@ type TickerPrice struct {
 func GetTickerPriceFromBinance(symbol string) (float64, error) {
 
 	var tp TickerPrice
+	//USD pairs are quoted in USDT, other quote currencies are listed as is
+	if strings.HasSuffix(strings.ToUpper(symbol), "-USD") {
+		symbol = fmt.Sprintf("%sT", symbol)
+	}
 	symbol = strings.ReplaceAll(symbol, "-", "")
-	symbol = fmt.Sprintf("%sT", symbol)
 
 	url := fmt.Sprintf("%s%s%s", baseURL, tickerURL, strings.ToUpper(symbol))
 
-	err := runHTTPGet(url, &tp)
+	err := runHTTPGet(ProviderBinance, url, &tp)
+	if he, ok := err.(*HTTPError); ok && he.StatusCode == http.StatusBadRequest {
+		//unknown symbols are rejected as bad requests
+		return 0.0, fmt.Errorf("%s %s: %w", ProviderBinance, symbol, ErrNotFound)
+	}
 	if err != nil {
 		return 0.0, err
 	} else {
@@ -36,3 +47,33 @@ func GetTickerPriceFromBinance(symbol string) (float64, error) {
 	price, _ := strconv.ParseFloat(tp.Price, 64)
 	return price, nil
 }
+
+//Binance provides the latest prices of crypto from the Binance API
+type Binance struct{}
+
+//Name returns the name of the provider
+func (Binance) Name() string {
+	return ProviderBinance
+}
+
+//Quotes returns the latest prices of the tickers. The error is of the last ticker without a price.
+func (Binance) Quotes(tickers store.Tickers) (map[string]*Quote, error) {
+
+	var err error
+	qm := make(map[string]*Quote)
+	for _, ticker := range tickers {
+		price, perr := GetTickerPriceFromBinance(ticker.Symbol)
+		if perr == nil && price <= 0 {
+			perr = fmt.Errorf("No price for %s", ticker.Symbol)
+		}
+		if errors.Is(perr, ErrRateLimited) {
+			return qm, perr
+		}
+		if perr != nil {
+			err = perr
+			continue
+		}
+		qm[ticker.GetTickerID()] = &Quote{Price: price}
+	}
+	return qm, err
+}
@@ -6,29 +6,248 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
-func runHTTPGet(url string, in interface{}) error {
+const (
+	//httpTimeout is the timeout of a request including reading the response
+	httpTimeout = 30 * time.Second
+	//maxRetries is the number of retries of a request failing with a temporary error
+	maxRetries = 3
+	//baseBackoff is the wait before the first retry, doubled on each retry
+	baseBackoff = 1 * time.Second
+	//maxRateWait is the longest wait for a rate limit token or a Retry-After before failing with a rate limit error
+	maxRateWait = 90 * time.Second
+)
+
+var httpClient = &http.Client{Timeout: httpTimeout}
+
+//HTTPError is returned by the providers for failed requests. StatusCode is zero when no response was received.
+//The URL is without the query, which holds the API tokens.
+type HTTPError struct {
+	Provider   string
+	URL        string
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *HTTPError) Error() string {
+	if e.StatusCode == 0 {
+		return fmt.Sprintf("%s %s: %v", e.Provider, e.URL, e.Err)
+	}
+	if len(e.Message) == 0 {
+		return fmt.Sprintf("%s %s: status %d", e.Provider, e.URL, e.StatusCode)
+	}
+	return fmt.Sprintf("%s %s: status %d %s", e.Provider, e.URL, e.StatusCode, e.Message)
+}
+
+//Unwrap returns the error of the request when no response was received
+func (e *HTTPError) Unwrap() error {
+	return e.Err
+}
+
+//Is matches ErrRateLimited for status 429 and ErrNotFound for status 404
+func (e *HTTPError) Is(target error) bool {
+	switch target {
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	}
+	return false
+}
+
+//Temporary returns true when the request is worth retrying: no response, rate limited or a server error
+func (e *HTTPError) Temporary() bool {
+	return e.StatusCode == 0 || e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= http.StatusInternalServerError
+}
+
+//RateLimitError is returned when the rate limit of the provider is reached, locally or as reported by the source
+type RateLimitError struct {
+	Provider string
+	Wait     time.Duration
+	Message  string
+}
+
+func (e *RateLimitError) Error() string {
+	if len(e.Message) > 0 {
+		return fmt.Sprintf("%s: %v: %s", e.Provider, ErrRateLimited, e.Message)
+	}
+	return fmt.Sprintf("%s: %v, next request in %v", e.Provider, ErrRateLimited, e.Wait.Round(time.Second))
+}
+
+//Is matches ErrRateLimited
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+//DecodeError is returned when the response of the provider cannot be decoded
+type DecodeError struct {
+	Provider string
+	URL      string
+	Err      error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Provider, e.URL, e.Err)
+}
+
+//Unwrap returns the json error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+//rateLimiter is a token bucket refilled continuously at the rate up to the burst
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+var (
+	limitersMu sync.Mutex
+	//limiters are the rate limits of the providers. AlphaVantage allows 5 requests a minute and
+	//Tiingo has an hourly quota depending on the plan.
+	limiters = map[string]*rateLimiter{
+		ProviderAlpha:   newRateLimiter(5, time.Minute),
+		ProviderTiingo:  newRateLimiter(10000, time.Hour),
+		ProviderBinance: newRateLimiter(1200, time.Minute),
+	}
+)
+
+func newRateLimiter(requests int, per time.Duration) *rateLimiter {
+	return &rateLimiter{rate: float64(requests) / per.Seconds(), burst: float64(requests), tokens: float64(requests), last: time.Now()}
+}
+
+//SetRateLimit sets the number of requests allowed to the provider over the duration
+func SetRateLimit(provider string, requests int, per time.Duration) {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	limiters[provider] = newRateLimiter(requests, per)
+}
+
+//reserve takes a token and returns the wait before it can be used. It takes no token and returns false
+//when the wait would be longer than maxRateWait.
+func (rl *rateLimiter) reserve() (time.Duration, bool) {
+
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+	now := time.Now()
+	rl.tokens = math.Min(rl.burst, rl.tokens+now.Sub(rl.last).Seconds()*rl.rate)
+	rl.last = now
+
+	var wait time.Duration
+	if rl.tokens < 1 {
+		wait = time.Duration((1 - rl.tokens) / rl.rate * float64(time.Second))
+	}
+	if wait > maxRateWait {
+		return wait, false
+	}
+	rl.tokens--
+	return wait, true
+}
+
+//throttle waits for a token of the provider
+func throttle(provider string) error {
+
+	limitersMu.Lock()
+	rl := limiters[provider]
+	limitersMu.Unlock()
+	if rl == nil {
+		return nil
+	}
+	wait, ok := rl.reserve()
+	if !ok {
+		return &RateLimitError{Provider: provider, Wait: wait}
+	}
+	time.Sleep(wait)
+	return nil
+}
+
+//runHTTPGet gets the url within the rate limit of the provider and decodes the json response.
+//Temporary errors are retried with exponential backoff, honoring the Retry-After of the response.
+func runHTTPGet(provider string, reqURL string, in interface{}) error {
+
+	var err error
+	backoff := baseBackoff
+	for attempt := 0; ; attempt++ {
+
+		if err = throttle(provider); err != nil {
+			return err
+		}
+		err = httpGet(provider, reqURL, in)
+		he, ok := err.(*HTTPError)
+		if !ok || !he.Temporary() || attempt == maxRetries {
+			return err
+		}
+
+		wait := backoff
+		if he.RetryAfter > wait {
+			wait = he.RetryAfter
+		}
+		if wait > maxRateWait {
+			return err
+		}
+		log.Printf("%v, retry %d in %v", err, attempt+1, wait)
+		time.Sleep(wait)
+		backoff *= 2
+	}
+}
+
+func httpGet(provider string, reqURL string, in interface{}) error {
 
 	// log.Printf("url: %s", url)
-	resp, err := http.Get(url)
+	resp, err := httpClient.Get(reqURL)
 	if err != nil {
-		// log.Print(err
-		return err
+		if ue, ok := err.(*url.Error); ok {
+			err = ue.Err
+		}
+		return &HTTPError{Provider: provider, URL: redactURL(reqURL), Err: err}
 	}
 
 	// log.Printf("%v", resp.Header.Get("X-MBX-USED-WEIGHT"))
 
 	defer resp.Body.Close()
-	body, _ := ioutil.ReadAll(resp.Body)
-	err1 := json.Unmarshal(body, &in)
-	if err1 != nil {
-		return err1
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return &HTTPError{Provider: provider, URL: redactURL(reqURL), Err: err}
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		he := &HTTPError{Provider: provider, URL: redactURL(reqURL), StatusCode: resp.StatusCode}
+		he.Message = strings.TrimSpace(string(body))
+		if len(he.Message) > 200 {
+			he.Message = he.Message[:200]
+		}
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			he.RetryAfter = time.Duration(secs) * time.Second
+		}
+		return he
 	}
 
+	if err := json.Unmarshal(body, &in); err != nil {
+		return &DecodeError{Provider: provider, URL: redactURL(reqURL), Err: err}
+	}
 	return nil
+}
 
+//redactURL returns the url without the query
+func redactURL(reqURL string) string {
+	u, err := url.Parse(reqURL)
+	if err != nil {
+		return ""
+	}
+	u.RawQuery = ""
+	return u.String()
 }
 
 func runHTTPPost(url string, p interface{}, in interface{}) error {
@@ -38,7 +257,7 @@ func runHTTPPost(url string, p interface{}, in interface{}) error {
 		return err
 	}
 
-	resp, err := http.Post(url, "applicatoin/json", bytes.NewBuffer(body))
+	resp, err := httpClient.Post(url, "applicatoin/json", bytes.NewBuffer(body))
 	if err != nil {
 		fmt.Println(err)
 		return err
@@ -10,7 +10,7 @@ func GetTickersDetails(baseURL string, symbols string) (store.Tickers, error) {
 	var tickers store.Tickers
 	var url = baseURL + "tickers?symbols=" + symbols
 	// log.Printf("url: %s", url)
-	err := runHTTPGet(url, &tickers)
+	err := runHTTPGet("", url, &tickers)
 	return tickers, err
 }
 
@@ -20,7 +20,7 @@ func GetTickerDetails(baseURL string, symbol string) (*store.Ticker, error) {
 	// log.Printf("url: %s", url)
 	var ticker *store.Ticker
 	var url = baseURL + "tickers/" + symbol
-	err := runHTTPGet(url, &ticker)
+	err := runHTTPGet("", url, &ticker)
 	return ticker, err
 }
 
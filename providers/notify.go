@@ -0,0 +1,26 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+//PostNotification posts the payload as json to the webhook url
+func PostNotification(url string, p interface{}) error {
+
+	body, err := json.Marshal(&p)
+	if err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Post(url, "application/json", bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("Notification failed with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,445 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rkapps/go_finance/store"
+)
+
+const (
+	//ProviderTiingo is the name of the Tiingo provider
+	ProviderTiingo string = "tiingo"
+	//ProviderAlpha is the name of the AlphaVantage provider
+	ProviderAlpha string = "alpha"
+	//ProviderBinance is the name of the Binance provider
+	ProviderBinance string = "binance"
+)
+
+const (
+	//DataHistory selects the providers of the daily history
+	DataHistory string = "history"
+	//DataQuote selects the providers of the latest quotes
+	DataQuote string = "quote"
+	//DataFundamentals selects the providers of the fundamentals
+	DataFundamentals string = "fundamentals"
+	//DataNews selects the providers of the news
+	DataNews string = "news"
+)
+
+const (
+	//maxFailures is the number of consecutive errors after which a provider is skipped
+	maxFailures = 5
+	//failureCooldown is how long a failing provider is skipped
+	failureCooldown = 5 * time.Minute
+	//rateLimitCooldown is how long a rate limited provider is skipped
+	rateLimitCooldown = 15 * time.Minute
+)
+
+var (
+	//ErrRateLimited is returned by the providers when the rate limit of the source is reached
+	ErrRateLimited = errors.New("Rate limit reached")
+	//ErrNotFound is returned by the providers when the source has no data for the ticker
+	ErrNotFound = errors.New("Not found")
+)
+
+var assetClasses = []string{store.AssetStock, store.AssetETF, store.AssetCrypto, store.AssetMutf, store.AssetIndex, store.AssetOther}
+
+//defaultSelections are the provider chains by data type and asset class when not configured
+var defaultSelections = map[string]map[string][]string{
+	DataHistory: {
+		store.AssetStock:  {ProviderTiingo},
+		store.AssetETF:    {ProviderTiingo},
+		store.AssetMutf:   {ProviderTiingo},
+		store.AssetCrypto: {ProviderTiingo},
+	},
+	DataQuote: {
+		store.AssetStock:  {ProviderTiingo},
+		store.AssetETF:    {ProviderTiingo},
+		store.AssetCrypto: {ProviderTiingo, ProviderBinance},
+		store.AssetIndex:  {ProviderAlpha},
+	},
+	DataFundamentals: {
+		store.AssetStock: {ProviderAlpha},
+		store.AssetETF:   {ProviderAlpha},
+	},
+	DataNews: {
+		store.AssetStock:  {ProviderTiingo},
+		store.AssetETF:    {ProviderTiingo},
+		store.AssetMutf:   {ProviderTiingo},
+		store.AssetCrypto: {ProviderTiingo},
+		store.AssetIndex:  {ProviderTiingo},
+		store.AssetOther:  {ProviderTiingo},
+	},
+}
+
+//Quote holds the latest price of a ticker and the provider that supplied it
+type Quote struct {
+	Price  float64
+	Prev   float64
+	Date   *time.Time
+	Source string
+}
+
+//stale returns true when the quote is dated before the last trading day, the last weekday on or before now
+//in the location of the quote. Quotes without a date are live.
+func (q *Quote) stale(now time.Time) bool {
+
+	if q.Date == nil {
+		return false
+	}
+	y, m, d := now.In(q.Date.Location()).Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, q.Date.Location())
+	for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+		day = day.AddDate(0, 0, -1)
+	}
+	return q.Date.Before(day)
+}
+
+//HistoryProvider returns the daily history of the tickers for the date range by ticker id
+type HistoryProvider interface {
+	Name() string
+	History(tickers store.Tickers, st time.Time, et time.Time) (map[string][]*store.TickerHistory, error)
+}
+
+//QuoteProvider returns the latest quotes of the tickers by ticker id
+type QuoteProvider interface {
+	Name() string
+	Quotes(tickers store.Tickers) (map[string]*Quote, error)
+}
+
+//FundamentalsProvider sets the fundamentals of the ticker
+type FundamentalsProvider interface {
+	Name() string
+	Fundamentals(ticker *store.Ticker) error
+}
+
+//NewsProvider returns the news of the tickers by ticker id
+type NewsProvider interface {
+	Name() string
+	News(tickers store.Tickers) (map[string][]store.TickerNews, error)
+}
+
+//ProviderHealth holds the outcome of the calls to a provider for a data type.
+//Failures counts the consecutive errors, the provider is skipped until SkipUntil.
+type ProviderHealth struct {
+	Provider      string     `json:"provider"`
+	Data          string     `json:"data"`
+	Calls         int        `json:"calls"`
+	Successes     int        `json:"successes"`
+	Empty         int        `json:"empty"`
+	Errors        int        `json:"errors"`
+	ErrorRate     float64    `json:"errorRate"`
+	AvgLatency    int64      `json:"avgLatencyMs"`
+	Failures      int        `json:"failures"`
+	LastError     string     `json:"lastError"`
+	LastErrorDate *time.Time `json:"lastErrorDate"`
+	SkipUntil     *time.Time `json:"skipUntil"`
+	latency       time.Duration
+}
+
+//Registry holds the providers by name, the chain of providers selected for each data type and asset class
+//and the health of the providers
+type Registry struct {
+	providers map[string]interface{}
+	selected  map[string]map[string][]string
+	mu        sync.Mutex
+	health    map[string]*ProviderHealth
+}
+
+//NewRegistry returns the registry of the Tiingo, AlphaVantage and Binance providers with the default chains
+//overridden by the configuration. The configuration lists data.class=provider,provider entries separated by
+//semicolons, for example "quote.crypto=binance,tiingo;history.index=alpha".
+func NewRegistry(config string) (*Registry, error) {
+
+	r := &Registry{providers: make(map[string]interface{}), selected: make(map[string]map[string][]string), health: make(map[string]*ProviderHealth)}
+	r.Register(ProviderTiingo, Tiingo{})
+	r.Register(ProviderAlpha, Alpha{})
+	r.Register(ProviderBinance, Binance{})
+	for data, classes := range defaultSelections {
+		r.selected[data] = make(map[string][]string)
+		for class, names := range classes {
+			r.selected[data][class] = names
+		}
+	}
+
+	for _, entry := range strings.Split(config, ";") {
+		entry = strings.TrimSpace(entry)
+		if len(entry) == 0 {
+			continue
+		}
+		kv := strings.SplitN(entry, "=", 2)
+		dc := strings.SplitN(kv[0], ".", 2)
+		if len(kv) != 2 || len(dc) != 2 {
+			return nil, fmt.Errorf("Provider configuration %s is not valid", entry)
+		}
+		var names []string
+		for _, name := range strings.Split(kv[1], ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+		if err := r.Select(strings.TrimSpace(dc[0]), strings.TrimSpace(dc[1]), names...); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+//Register adds the provider under the name, replacing the provider registered with the same name
+func (r *Registry) Register(name string, p interface{}) {
+	r.providers[name] = p
+}
+
+//Select selects the chain of registered providers for the data type of the asset class, tried in order.
+//No names removes the selection.
+func (r *Registry) Select(data string, class string, names ...string) error {
+
+	if _, ok := r.selected[data]; !ok {
+		return fmt.Errorf("Provider data type %s is not valid", data)
+	}
+	valid := false
+	for _, ac := range assetClasses {
+		valid = valid || strings.Compare(ac, class) == 0
+	}
+	if !valid {
+		return fmt.Errorf("Asset class %s is not valid", class)
+	}
+	if len(names) == 0 {
+		delete(r.selected[data], class)
+		return nil
+	}
+
+	for _, name := range names {
+		p, ok := r.providers[name]
+		if !ok {
+			return fmt.Errorf("Provider %s is not registered", name)
+		}
+		switch data {
+		case DataHistory:
+			_, ok = p.(HistoryProvider)
+		case DataQuote:
+			_, ok = p.(QuoteProvider)
+		case DataFundamentals:
+			_, ok = p.(FundamentalsProvider)
+		case DataNews:
+			_, ok = p.(NewsProvider)
+		}
+		if !ok {
+			return fmt.Errorf("Provider %s does not provide %s", name, data)
+		}
+	}
+	r.selected[data][class] = names
+	return nil
+}
+
+//History returns the daily history of the tickers of the asset class for the date range by ticker id
+//and the provider of the history of each ticker. The error of the last provider failing is returned with the data found.
+func (r *Registry) History(class string, tickers store.Tickers, st time.Time, et time.Time) (map[string][]*store.TickerHistory, map[string]string, error) {
+
+	thm := make(map[string][]*store.TickerHistory)
+	sources, err := r.chain(DataHistory, class, tickers, func(p interface{}, ts store.Tickers) ([]string, error) {
+		m, err := p.(HistoryProvider).History(ts, st, et)
+		var ids []string
+		for id, ths := range m {
+			if len(ths) > 0 {
+				thm[id] = ths
+				ids = append(ids, id)
+			}
+		}
+		return ids, err
+	})
+	return thm, sources, err
+}
+
+//Quotes returns the latest quotes of the tickers of the asset class by ticker id.
+//Quotes dated before the last trading day are misses, so the next provider is tried for them. The latest of the stale
+//quotes is returned when no provider has a current quote. The error of the last provider failing is returned with the quotes found.
+func (r *Registry) Quotes(class string, tickers store.Tickers) (map[string]*Quote, error) {
+
+	qm := make(map[string]*Quote)
+	sm := make(map[string]*Quote)
+	now := time.Now()
+	sources, err := r.chain(DataQuote, class, tickers, func(p interface{}, ts store.Tickers) ([]string, error) {
+		m, err := p.(QuoteProvider).Quotes(ts)
+		var ids []string
+		for id, q := range m {
+			if q == nil || q.Price <= 0 {
+				continue
+			}
+			if q.stale(now) {
+				if sq := sm[id]; sq == nil || q.Date.After(*sq.Date) {
+					q.Source = p.(QuoteProvider).Name()
+					sm[id] = q
+				}
+				continue
+			}
+			qm[id] = q
+			ids = append(ids, id)
+		}
+		return ids, err
+	})
+	for id, q := range qm {
+		q.Source = sources[id]
+	}
+	for id, q := range sm {
+		if qm[id] == nil {
+			qm[id] = q
+		}
+	}
+	return qm, err
+}
+
+//Fundamentals sets the fundamentals of the ticker and returns the provider that set them. It returns the error
+//of the last provider tried when none did. The provider is blank when no provider is available.
+func (r *Registry) Fundamentals(ticker *store.Ticker) (string, error) {
+
+	sources, err := r.chain(DataFundamentals, ticker.AssetClass(), store.Tickers{ticker}, func(p interface{}, ts store.Tickers) ([]string, error) {
+		if err := p.(FundamentalsProvider).Fundamentals(ts[0]); err != nil {
+			return nil, err
+		}
+		return []string{ts[0].GetTickerID()}, nil
+	})
+	if source, ok := sources[ticker.GetTickerID()]; ok {
+		return source, nil
+	}
+	return "", err
+}
+
+//News returns the news of the tickers of the asset class by ticker id.
+//The error of the last provider failing is returned with the news found.
+func (r *Registry) News(class string, tickers store.Tickers) (map[string][]store.TickerNews, error) {
+
+	tnm := make(map[string][]store.TickerNews)
+	_, err := r.chain(DataNews, class, tickers, func(p interface{}, ts store.Tickers) ([]string, error) {
+		m, err := p.(NewsProvider).News(ts)
+		var ids []string
+		for id, tns := range m {
+			if len(tns) > 0 {
+				tnm[id] = tns
+				ids = append(ids, id)
+			}
+		}
+		return ids, err
+	})
+	return tnm, err
+}
+
+//Health returns the health of the providers called by provider and data type
+func (r *Registry) Health() []*ProviderHealth {
+
+	var phs []*ProviderHealth
+	if r == nil {
+		return phs
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, h := range r.health {
+		ph := *h
+		if ph.Calls > 0 {
+			ph.ErrorRate = float64(ph.Errors) * 100 / float64(ph.Calls)
+			ph.AvgLatency = (ph.latency / time.Duration(ph.Calls)).Milliseconds()
+		}
+		phs = append(phs, &ph)
+	}
+	sort.Slice(phs, func(i, j int) bool {
+		if strings.Compare(phs[i].Provider, phs[j].Provider) == 0 {
+			return strings.Compare(phs[i].Data, phs[j].Data) < 0
+		}
+		return strings.Compare(phs[i].Provider, phs[j].Provider) < 0
+	})
+	return phs
+}
+
+//chain calls the providers of the data type for the asset class in order with the tickers not yet found,
+//skipping the providers that are failing or rate limited. The call returns the ids of the tickers found.
+//It returns the provider of each ticker found and the last error.
+func (r *Registry) chain(data string, class string, tickers store.Tickers, call func(p interface{}, ts store.Tickers) ([]string, error)) (map[string]string, error) {
+
+	var lastErr error
+	sources := make(map[string]string)
+	if r == nil {
+		return sources, lastErr
+	}
+	for _, name := range r.selected[data][class] {
+
+		var ts store.Tickers
+		for _, ticker := range tickers {
+			if _, ok := sources[ticker.GetTickerID()]; !ok {
+				ts = append(ts, ticker)
+			}
+		}
+		if len(ts) == 0 {
+			break
+		}
+		if !r.available(name, data) {
+			continue
+		}
+
+		start := time.Now()
+		ids, err := call(r.providers[name], ts)
+		r.record(name, data, time.Since(start), len(ids) > 0, err)
+		if err != nil {
+			lastErr = fmt.Errorf("%s: %w", name, err)
+		}
+		for _, id := range ids {
+			sources[id] = name
+		}
+	}
+	return sources, lastErr
+}
+
+//available returns false while the provider is skipped for the data type
+func (r *Registry) available(name string, data string) bool {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	h := r.health[name+":"+data]
+	return h == nil || h.SkipUntil == nil || time.Now().After(*h.SkipUntil)
+}
+
+//record records the outcome of a call. Calls finding no data without an error or with ErrNotFound are empty,
+//the other calls finding no data are errors. The provider is skipped after consecutive errors or when rate limited.
+func (r *Registry) record(name string, data string, latency time.Duration, found bool, err error) {
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := name + ":" + data
+	h := r.health[key]
+	if h == nil {
+		h = &ProviderHealth{Provider: name, Data: data}
+		r.health[key] = h
+	}
+	h.Calls++
+	h.latency += latency
+
+	now := time.Now()
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		h.LastError = err.Error()
+		h.LastErrorDate = &now
+	}
+	switch {
+	case found:
+		h.Successes++
+		h.Failures = 0
+		h.SkipUntil = nil
+	case err == nil || errors.Is(err, ErrNotFound):
+		h.Empty++
+	default:
+		h.Errors++
+		h.Failures++
+		var until time.Time
+		if errors.Is(err, ErrRateLimited) {
+			until = now.Add(rateLimitCooldown)
+		} else if h.Failures >= maxFailures {
+			until = now.Add(failureCooldown)
+		}
+		if !until.IsZero() {
+			h.SkipUntil = &until
+		}
+	}
+}
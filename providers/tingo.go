@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -17,6 +18,7 @@ const (
 	cryptoURL = "https://api.tiingo.com/tiingo/crypto/prices"
 	fundasURL = "https://api.tiingo.com/tiingo/fundamentals/"
 	newsURL   = "https://api.tiingo.com/tiingo/news"
+	fxURL     = "https://api.tiingo.com/tiingo/fx/"
 )
 
 type cResponse struct {
@@ -40,21 +42,23 @@ type nResponse struct {
 }
 
 //GetTickerRealTimeQuote returns the real time last price and date
-func GetTickerRealTimeQuote(ticker store.Ticker) (float64, *time.Time) {
+func GetTickerRealTimeQuote(ticker store.Ticker) (float64, *time.Time, error) {
 
 	url := strings.Join([]string{rTimeURL, ticker.Symbol, "?token=", apiToken}, "")
 	// log.Printf("url: %s", url)
 	var s = new([]rResponse)
-	runHTTPGet(url, &s)
+	if err := runHTTPGet(ProviderTiingo, url, &s); err != nil {
+		return 0.0, nil, err
+	}
 	for _, res := range *s {
 		// log.Println(res)
-		return res.TngoLast, res.Date
+		return res.TngoLast, res.Date, nil
 	}
-	return 0.0, nil
+	return 0.0, nil, nil
 }
 
 //GetCryptoHistory returns the EOD quotes for the date range
-func GetCryptoHistory(tickers store.Tickers, st time.Time, et time.Time) map[string][]*store.TickerHistory {
+func GetCryptoHistory(tickers store.Tickers, st time.Time, et time.Time) (map[string][]*store.TickerHistory, error) {
 
 	var thm = make(map[string][]*store.TickerHistory)
 	var sBuilder strings.Builder
@@ -62,7 +66,7 @@ func GetCryptoHistory(tickers store.Tickers, st time.Time, et time.Time) map[str
 
 	for _, ticker := range tickers {
 		if ticker.IsCrypto() {
-			ts := strings.ToLower(strings.Replace(ticker.Symbol, "-USD", "USD", 1))
+			ts := strings.ToLower(strings.Replace(ticker.Symbol, "-", "", 1))
 			sBuilder.WriteString(ts)
 			sBuilder.WriteString(",")
 			tm[ts] = ticker
@@ -70,7 +74,7 @@ func GetCryptoHistory(tickers store.Tickers, st time.Time, et time.Time) map[str
 	}
 
 	if len(sBuilder.String()) == 0 {
-		return thm
+		return thm, nil
 	}
 
 	dates := fmt.Sprintf("&startDate=%s&endDate=%s", utils.DateFormat1(st), utils.DateFormat1(et))
@@ -78,7 +82,9 @@ func GetCryptoHistory(tickers store.Tickers, st time.Time, et time.Time) map[str
 	// log.Printf("url: %s", url)
 	var s = new([]cResponse)
 
-	runHTTPGet(url, s)
+	if err := runHTTPGet(ProviderTiingo, url, s); err != nil {
+		return thm, err
+	}
 
 	for _, res := range *s {
 		t := tm[res.Symbol]
@@ -93,31 +99,49 @@ func GetCryptoHistory(tickers store.Tickers, st time.Time, et time.Time) map[str
 		}
 	}
 
-	return thm
+	return thm, nil
 }
 
 //GetTickerHistoryQuotes returns the historical EOD quotes between the time range.
-func GetTickersHistory(tickers store.Tickers, st time.Time, et time.Time) map[string][]*store.TickerHistory {
+//The error is of the last ticker that failed.
+func GetTickersHistory(tickers store.Tickers, st time.Time, et time.Time) (map[string][]*store.TickerHistory, error) {
+	var err error
 	var thm = make(map[string][]*store.TickerHistory)
 	for _, ticker := range tickers {
 		if ticker.IsStock() || ticker.IsMutf() {
-			ths := getTickerQuoteFromTingo(*ticker, st, et)
+			ths, terr := getTickerQuoteFromTingo(*ticker, st, et)
+			if errors.Is(terr, ErrRateLimited) {
+				return thm, terr
+			}
+			if terr != nil {
+				err = terr
+				continue
+			}
 			thm[ticker.GetTickerID()] = ths
 		}
 	}
-	return thm
+	return thm, err
 }
 
-//GetTickerNews returns the news for the ticker
-func GetTickerNews(tickers store.Tickers) map[string][]store.TickerNews {
+//GetTickerNews returns the news for the ticker. The error is of the last ticker that failed.
+func GetTickerNews(tickers store.Tickers) (map[string][]store.TickerNews, error) {
+	var err error
 	var tnm = make(map[string][]store.TickerNews)
 	for _, ticker := range tickers {
-		tnm[ticker.GetTickerID()] = getTickerNews(ticker)
+		tns, terr := getTickerNews(ticker)
+		if errors.Is(terr, ErrRateLimited) {
+			return tnm, terr
+		}
+		if terr != nil {
+			err = terr
+			continue
+		}
+		tnm[ticker.GetTickerID()] = tns
 	}
-	return tnm
+	return tnm, err
 }
 
-func getTickerNews(ticker *store.Ticker) []store.TickerNews {
+func getTickerNews(ticker *store.Ticker) ([]store.TickerNews, error) {
 
 	var tnr []nResponse
 	// var tns []core.TickerNews
@@ -129,7 +153,9 @@ func getTickerNews(ticker *store.Ticker) []store.TickerNews {
 	url := strings.Join([]string{newsURL, "?tickers=", symbol, "&token=", apiToken}, "")
 	// tg.runTickerAPI(url, &tnr)
 
-	runHTTPGet(url, &tnr)
+	if err := runHTTPGet(ProviderTiingo, url, &tnr); err != nil {
+		return nil, err
+	}
 
 	nmap := make(map[string]store.TickerNews)
 
@@ -151,18 +177,20 @@ func getTickerNews(ticker *store.Ticker) []store.TickerNews {
 		}
 	}
 	// log.Println(tfs)
-	return rtns
+	return rtns, nil
 
 }
 
 //GetEODTickerQuote gets EOD quote for a ticker
-func getTickerQuoteFromTingo(ticker store.Ticker, st time.Time, et time.Time) []*store.TickerHistory {
+func getTickerQuoteFromTingo(ticker store.Ticker, st time.Time, et time.Time) ([]*store.TickerHistory, error) {
 
 	var ths []*store.TickerHistory
 	dates := fmt.Sprintf("&startDate=%s&endDate=%s", utils.DateFormat1(st), utils.DateFormat1(et))
 	url := strings.Join([]string{eodURL, ticker.Symbol, "/prices?token=", apiToken, dates}, "")
 	// log.Printf("url: %s", url)
-	runHTTPGet(url, &ths)
+	if err := runHTTPGet(ProviderTiingo, url, &ths); err != nil {
+		return nil, err
+	}
 	//	ticker.LastPrice = ((ths)[0].Close)
 	for _, th := range ths {
 		// th.Exchange = ticker.Exchange
@@ -174,5 +202,109 @@ func getTickerQuoteFromTingo(ticker store.Ticker, st time.Time, et time.Time) []
 		th.Open = th.AdjOpen
 	}
 
-	return ths
+	return ths, nil
+}
+
+type fxResponse struct {
+	Date  time.Time `json:"date"`
+	Close float64   `json:"close"`
+}
+
+//GetFXHistory returns the daily value in USD of one unit of the currency for the date range.
+//Pairs quoted in the other direction are inverted.
+func GetFXHistory(currency string, st time.Time, et time.Time) (store.FXRates, error) {
+
+	currency = strings.ToUpper(currency)
+	var rates store.FXRates
+	var perr error
+	for _, pair := range []string{currency + "USD", "USD" + currency} {
+
+		dates := fmt.Sprintf("?startDate=%s&endDate=%s", utils.DateFormat1(st), utils.DateFormat1(et))
+		url := strings.Join([]string{fxURL, strings.ToLower(pair), "/prices", dates, "&resampleFreq=1day&token=", apiToken}, "")
+		var s []fxResponse
+		if err := runHTTPGet(ProviderTiingo, url, &s); err != nil {
+			//the inverse pair is tried unless the quota is used up
+			if errors.Is(err, ErrRateLimited) {
+				return nil, err
+			}
+			perr = err
+			continue
+		}
+		for _, res := range s {
+			if res.Close <= 0 {
+				continue
+			}
+			date := res.Date.UTC()
+			rate := &store.FXRate{Currency: currency, Date: time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC), Rate: res.Close}
+			if strings.HasPrefix(pair, "USD") {
+				rate.Rate = 1 / res.Close
+			}
+			rates = append(rates, rate)
+		}
+		if len(rates) > 0 {
+			return rates, nil
+		}
+	}
+	return rates, perr
+}
+
+//Tiingo provides the daily history, quotes and news from the Tiingo API
+type Tiingo struct{}
+
+//Name returns the name of the provider
+func (Tiingo) Name() string {
+	return ProviderTiingo
+}
+
+//History returns the daily history of the stocks, mutual funds and crypto for the date range
+func (Tiingo) History(tickers store.Tickers, st time.Time, et time.Time) (map[string][]*store.TickerHistory, error) {
+
+	thm, err := GetCryptoHistory(tickers, st, et)
+	if errors.Is(err, ErrRateLimited) {
+		return thm, err
+	}
+	sthm, serr := GetTickersHistory(tickers, st, et)
+	for id, ths := range sthm {
+		thm[id] = ths
+	}
+	if serr != nil {
+		err = serr
+	}
+	return thm, err
+}
+
+//Quotes returns the real time quotes of the stocks and the latest daily close of crypto
+func (Tiingo) Quotes(tickers store.Tickers) (map[string]*Quote, error) {
+
+	qm := make(map[string]*Quote)
+	today := time.Now()
+	thm, err := GetCryptoHistory(tickers, today, today.Add(time.Hour*48))
+	if errors.Is(err, ErrRateLimited) {
+		return qm, err
+	}
+	for id, ths := range thm {
+		if len(ths) > 0 {
+			th := ths[len(ths)-1]
+			qm[id] = &Quote{Price: th.Close, Date: &th.Date}
+		}
+	}
+	for _, ticker := range tickers {
+		if ticker.IsStock() {
+			price, date, qerr := GetTickerRealTimeQuote(*ticker)
+			if errors.Is(qerr, ErrRateLimited) {
+				return qm, qerr
+			}
+			if qerr != nil {
+				err = qerr
+			} else if date != nil {
+				qm[ticker.GetTickerID()] = &Quote{Price: price, Date: date}
+			}
+		}
+	}
+	return qm, err
+}
+
+//News returns the news of the tickers
+func (Tiingo) News(tickers store.Tickers) (map[string][]store.TickerNews, error) {
+	return GetTickerNews(tickers)
 }
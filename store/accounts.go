@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+)
+
+const (
+	//AcctTaxable defines a taxable brokerage account
+	AcctTaxable string = "taxable"
+	//AcctIRA defines a traditional IRA
+	AcctIRA string = "IRA"
+	//AcctRoth defines a Roth IRA
+	AcctRoth string = "Roth"
+	//Acct401k defines a 401k plan
+	Acct401k string = "401k"
+	//AcctHSA defines a health savings account
+	AcctHSA string = "HSA"
+	//AcctWallet defines a crypto wallet
+	AcctWallet string = "wallet"
+	//AcctExchange defines a crypto exchange account
+	AcctExchange string = "exchange"
+)
+
+const (
+	//TaxTaxable defines gains taxed when realized
+	TaxTaxable string = "taxable"
+	//TaxDeferred defines gains taxed on withdrawal
+	TaxDeferred string = "tax-deferred"
+	//TaxExempt defines gains not taxed
+	TaxExempt string = "tax-exempt"
+)
+
+const (
+	//AcctOpen defines an open account
+	AcctOpen string = "O"
+	//AcctClosed defines a closed account
+	AcctClosed string = "C"
+)
+
+const (
+	//BasisFIFO relieves the oldest lots first
+	BasisFIFO string = "FIFO"
+	//BasisLIFO relieves the newest lots first
+	BasisLIFO string = "LIFO"
+	//BasisHIFO relieves the highest cost lots first
+	BasisHIFO string = "HIFO"
+)
+
+//InvAccount holds the details of an investment account
+type InvAccount struct {
+	UID          string             `json:"-"`
+	ID           primitive.ObjectID `json:"id" bson:"_id"`
+	Group        string             `json:"group" bson:"group"`
+	Category     string             `json:"category" bson:"category"`
+	Account      string             `json:"account" bson:"account"`
+	Institution  string             `json:"institution" bson:"institution"`
+	Type         string             `json:"type" bson:"type"`
+	TaxTreatment string             `json:"taxTreatment" bson:"taxTreatment"`
+	Currency     string             `json:"currency" bson:"currency"`
+	Status       string             `json:"status" bson:"status"`
+	CostBasis    string             `json:"costBasis" bson:"costBasis"`
+}
+
+//InvAccounts holds an array of accounts.
+type InvAccounts []*InvAccount
+
+func createAccountsIndices(ctx context.Context, col *mongo.Collection) {
+
+	keys := bsonx.Doc{{Key: "UID", Value: bsonx.Int32(1)}}
+	createIndex(ctx, col, "idx_UID", keys, false)
+
+	keys = bsonx.Doc{{Key: "UID", Value: bsonx.Int32(1)}, {Key: "account", Value: bsonx.Int32(1)}}
+	createIndex(ctx, col, "idx_UID_account", keys, true)
+}
+
+//InvestmentsAccountsUpdate updates investment accounts
+func (mdb *MongoDB) InvestmentsAccountsUpdate(ctx context.Context, accts InvAccounts) error {
+
+	user := UserFromCtx(ctx)
+	if len(accts) == 0 {
+		return nil
+	}
+
+	var operations []mongo.WriteModel
+	for _, acct := range accts {
+		if acct.ID.IsZero() {
+			acct.ID = primitive.NewObjectID()
+		}
+		acct.UID = user.UID
+		operation := mongo.NewUpdateManyModel()
+		operation.SetFilter(bson.M{"UID": acct.UID, "_id": acct.ID})
+		update := bson.M{"$set": acct}
+		operation.SetUpdate(update)
+		operation.SetUpsert(true)
+		operations = append(operations, operation)
+	}
+
+	bulkOption := options.BulkWriteOptions{}
+	bulkOption.SetOrdered(true)
+	col := mdb.db.Collection(ACCOUNTScol)
+	_, err := col.BulkWrite(context.TODO(), operations, &bulkOption)
+	return err
+}
+
+//DeleteInvestmentsAccount deletes the investment account for the id
+func (mdb *MongoDB) DeleteInvestmentsAccount(ctx context.Context, id primitive.ObjectID) error {
+
+	user := UserFromCtx(ctx)
+	col := mdb.db.Collection(ACCOUNTScol)
+	_, err := col.DeleteOne(ctx, bson.M{"UID": user.UID, "_id": id})
+	return err
+}
+
+//InvestmentsAccounts returns the investment accounts sorted by group, category and account
+func (mdb *MongoDB) InvestmentsAccounts(ctx context.Context) InvAccounts {
+
+	var options = options.Find()
+	user := UserFromCtx(ctx)
+	query := bson.M{"UID": bson.M{"$eq": user.UID}}
+	options.SetSort(bson.D{{"group", 1}, {"category", 1}, {"account", 1}})
+
+	var result InvAccounts
+	col := mdb.db.Collection(ACCOUNTScol)
+	cur, err := col.Find(context.TODO(), query, options)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+	} else {
+		err = cur.All(context.TODO(), &result)
+		if err != nil {
+			log.Printf("Cursor error: %v\n", err)
+		}
+	}
+	return result
+}
@@ -2,7 +2,9 @@ package store
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"math"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
@@ -28,13 +30,55 @@ type Activity struct {
 	Symbol      string             `json:"symbol" bson:"symbol"`
 	Qty         float64            `json:"qty" bson:"qty"`
 	Price       float64            `json:"price" bson:"price"`
+	Currency    string             `json:"currency,omitempty" bson:"currency,omitempty"`
 	ToAccount   string             `json:"toAccount" bson:"toAccount"`
 	Fee         float64            `json:"fee" bson:"fee"`
+	Splits      []*ActivitySplit   `json:"splits,omitempty" bson:"splits,omitempty"`
+	Transfer    string             `json:"transfer" bson:"transfer,omitempty"`
+	TransferID  primitive.ObjectID `json:"transferId" bson:"transferId,omitempty"`
+	TaxYear     int32              `json:"taxYear,omitempty" bson:"taxYear,omitempty"`
 }
 
 //Activities holds an array of activity.
 type Activities []*Activity
 
+const (
+	//TransferMatched defines a transfer paired with the activity on the other account
+	TransferMatched string = "M"
+	//TransferUnmatched defines a transfer without a matching activity, waiting for review
+	TransferUnmatched string = "U"
+	//TransferConfirmed defines a transfer confirmed on review without a matching activity
+	TransferConfirmed string = "C"
+)
+
+//ActivitySplit holds the part of a transaction assigned to a group and category
+type ActivitySplit struct {
+	Amount   float64 `json:"amount" bson:"amount"`
+	Group    string  `json:"group" bson:"group"`
+	Category string  `json:"category" bson:"category"`
+	Memo     string  `json:"memo" bson:"memo"`
+}
+
+//ValidateSplits returns an error if the split lines do not add up to the amount
+func (actv *Activity) ValidateSplits() error {
+
+	if len(actv.Splits) == 0 {
+		return nil
+	}
+
+	var total float64
+	for _, split := range actv.Splits {
+		if len(split.Group) == 0 || len(split.Category) == 0 {
+			return fmt.Errorf("Split of %s on %v is missing group or category", actv.Description, actv.Date)
+		}
+		total += split.Amount
+	}
+	if math.Abs(total-actv.Amount) >= 0.005 {
+		return fmt.Errorf("Splits of %s on %v total %.2f instead of %.2f", actv.Description, actv.Date, total, actv.Amount)
+	}
+	return nil
+}
+
 func createActivitiesIndices(ctx context.Context, col *mongo.Collection) {
 
 	keys := bsonx.Doc{{Key: "UID", Value: bsonx.Int32(1)}}
@@ -136,8 +180,71 @@ func (mdb *MongoDB) ActivitiesUpdate(ctx context.Context, actvs Activities) erro
 
 }
 
-//InvAccounts returns investment accounts
-func (mdb *MongoDB) InvestmentsAccounts(ctx context.Context) (InvAccounts, error) {
+//GetActivity returns the activity for the id
+func (mdb *MongoDB) GetActivity(ctx context.Context, id primitive.ObjectID) *Activity {
+
+	user := UserFromCtx(ctx)
+	query := bson.M{"UID": user.UID, "_id": id}
+	actvs := mdb.getActivities(query, nil)
+	if len(actvs) > 0 {
+		return actvs[0]
+	}
+	return nil
+}
+
+//ActivitySplitsUpdate replaces the split lines of the activity
+func (mdb *MongoDB) ActivitySplitsUpdate(ctx context.Context, id primitive.ObjectID, splits []*ActivitySplit) error {
+
+	user := UserFromCtx(ctx)
+	var update bson.M
+	if len(splits) == 0 {
+		update = bson.M{"$unset": bson.M{"splits": ""}}
+	} else {
+		update = bson.M{"$set": bson.M{"splits": splits}}
+	}
+	actvsCol := mdb.db.Collection(ACTVScol)
+	_, err := actvsCol.UpdateOne(ctx, bson.M{"UID": user.UID, "_id": id}, update)
+	return err
+}
+
+//ActivitiesTransferUpdate updates the transfer status and the matching activity
+func (mdb *MongoDB) ActivitiesTransferUpdate(ctx context.Context, actvs Activities) error {
+
+	user := UserFromCtx(ctx)
+	if len(actvs) == 0 {
+		return nil
+	}
+
+	var operations []mongo.WriteModel
+	for _, actv := range actvs {
+		operation := mongo.NewUpdateOneModel()
+		operation.SetFilter(bson.M{"UID": user.UID, "_id": actv.ID})
+		update := bson.M{"$set": bson.M{
+			"transfer":   actv.Transfer,
+			"transferId": actv.TransferID,
+		}}
+		operation.SetUpdate(update)
+		operations = append(operations, operation)
+	}
+
+	bulkOption := options.BulkWriteOptions{}
+	bulkOption.SetOrdered(true)
+	col := mdb.db.Collection(ACTVScol)
+	_, err := col.BulkWrite(context.TODO(), operations, &bulkOption)
+	return err
+}
+
+//DeleteActivity deletes the activity for the id
+func (mdb *MongoDB) DeleteActivity(ctx context.Context, id primitive.ObjectID) error {
+
+	user := UserFromCtx(ctx)
+	actvsCol := mdb.db.Collection(ACTVScol)
+	_, err := actvsCol.DeleteOne(ctx, bson.M{"UID": user.UID, "_id": id})
+	return err
+}
+
+//InvestmentsActivityAccounts returns the accounts by group and category found in the investment activities
+func (mdb *MongoDB) InvestmentsActivityAccounts(ctx context.Context) (InvAccounts, error) {
 
 	user := UserFromCtx(ctx)
 
@@ -171,13 +278,19 @@ func (mdb *MongoDB) InvestmentsAccounts(ctx context.Context) (InvAccounts, error
 		return nil, err
 	}
 
-	var results []map[string]interface{}
+	var results []struct {
+		ID InvAccount `bson:"_id"`
+	}
 	if err = cursor.All(context.TODO(), &results); err != nil {
-		log.Fatal(err)
+		log.Printf("Cursor error: %v", err)
+		return nil, err
 	}
 
-	// log.Println(results)
-	return nil, nil
+	var accts InvAccounts
+	for x := range results {
+		accts = append(accts, &results[x].ID)
+	}
+	return accts, nil
 }
 
 //InvestmentsOpenActivities returns all investment activities
@@ -0,0 +1,17 @@
+package store
+
+//Allocation holds the market value of the holdings in a slice of a dimension
+//with the drill-down into the next dimension, ending with the symbols.
+//Percent is the share of the total market value and Weight the share of the parent slice.
+type Allocation struct {
+	Dimension   string      `json:"dimension"`
+	Name        string      `json:"name"`
+	MktValue    float64     `json:"mktValue"`
+	CostValue   float64     `json:"costValue"`
+	Percent     float64     `json:"percent"`
+	Weight      float64     `json:"weight"`
+	Allocations Allocations `json:"allocations,omitempty"`
+}
+
+//Allocations holds an array of allocation.
+type Allocations []*Allocation
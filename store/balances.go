@@ -0,0 +1,150 @@
+package store
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+)
+
+const (
+	//KindBank defines the string Bank
+	KindBank string = "Bank"
+	//KindCreditCard defines the string Credit Card
+	KindCreditCard string = "Credit Card"
+	//KindMortgage defines the string Mortgage
+	KindMortgage string = "Mortgage"
+	//KindLoan defines the string Loan
+	KindLoan string = "Loan"
+	//KindRealEstate defines the string Real Estate
+	KindRealEstate string = "Real Estate"
+	//KindVehicle defines the string Vehicle
+	KindVehicle string = "Vehicle"
+	//KindOther defines the string Other
+	KindOther string = "Other"
+)
+
+//Balance holds the balance or valuation of an account on a date
+type Balance struct {
+	UID      string             `json:"-"`
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	Account  string             `json:"account" bson:"account"`
+	Kind     string             `json:"kind" bson:"kind"`
+	Date     *time.Time         `json:"date" bson:"date"`
+	Value    float64            `json:"value" bson:"value"`
+	Currency string             `json:"currency,omitempty" bson:"currency,omitempty"`
+	Memo     string             `json:"memo" bson:"memo"`
+}
+
+//Balances holds an array of balances.
+type Balances []*Balance
+
+//NetWorth holds the assets and liabilities at the end of a month in the currency
+type NetWorth struct {
+	Date        *time.Time         `json:"date"`
+	Assets      float64            `json:"assets"`
+	Investments float64            `json:"investments"`
+	Liabilities float64            `json:"liabilities"`
+	NetWorth    float64            `json:"netWorth"`
+	Currency    string             `json:"currency"`
+	Accounts    map[string]float64 `json:"accounts"`
+}
+
+//NetWorths holds an array of net worth.
+type NetWorths []*NetWorth
+
+func createBalancesIndices(ctx context.Context, col *mongo.Collection) {
+
+	keys := bsonx.Doc{{Key: "UID", Value: bsonx.Int32(1)}}
+	createIndex(ctx, col, "idx_UID", keys, false)
+
+	keys = bsonx.Doc{{Key: "account", Value: bsonx.Int32(1)}}
+	createIndex(ctx, col, "idx_account", keys, false)
+
+	keys = bsonx.Doc{{Key: "date", Value: bsonx.Int32(1)}}
+	createIndex(ctx, col, "idx_date", keys, false)
+}
+
+//IsLiability returns true if the balance is owed
+func (b Balance) IsLiability() bool {
+	return strings.Compare(b.Kind, KindCreditCard) == 0 ||
+		strings.Compare(b.Kind, KindMortgage) == 0 ||
+		strings.Compare(b.Kind, KindLoan) == 0
+}
+
+//BalancesUpdate updates balances
+func (mdb *MongoDB) BalancesUpdate(ctx context.Context, bals Balances) error {
+
+	user := UserFromCtx(ctx)
+	if len(bals) == 0 {
+		return nil
+	}
+
+	var operations []mongo.WriteModel
+
+	for _, bal := range bals {
+		if bal.ID.IsZero() {
+			bal.ID = primitive.NewObjectID()
+		}
+		bal.UID = user.UID
+		operation := mongo.NewUpdateManyModel()
+		operation.SetFilter(bson.M{"UID": bal.UID, "_id": bal.ID})
+		update := bson.M{"$set": bal}
+		operation.SetUpdate(update)
+		operation.SetUpsert(true)
+		operations = append(operations, operation)
+	}
+
+	bulkOption := options.BulkWriteOptions{}
+	bulkOption.SetOrdered(true)
+	col := mdb.db.Collection(BALANCEScol)
+	_, err := col.BulkWrite(context.TODO(), operations, &bulkOption)
+	return err
+}
+
+//DeleteBalance deletes the balance for the id
+func (mdb *MongoDB) DeleteBalance(ctx context.Context, id primitive.ObjectID) error {
+
+	user := UserFromCtx(ctx)
+	col := mdb.db.Collection(BALANCEScol)
+	_, err := col.DeleteOne(ctx, bson.M{"UID": user.UID, "_id": id})
+	return err
+}
+
+//Balances returns the balances of the account up to the date sorted by date
+func (mdb *MongoDB) Balances(ctx context.Context, account string, toDate *time.Time) Balances {
+
+	var options = options.Find()
+	var query map[string]interface{}
+	query = make(map[string]interface{})
+
+	user := UserFromCtx(ctx)
+	query["UID"] = bson.M{"$eq": user.UID}
+	if len(account) > 0 {
+		query["account"] = bson.M{"$eq": account}
+	}
+	if toDate != nil {
+		query["date"] = bson.M{"$lte": toDate}
+	}
+
+	options.SetSort(bson.D{{"date", 1}})
+
+	var result Balances
+	col := mdb.db.Collection(BALANCEScol)
+	cur, err := col.Find(context.TODO(), query, options)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+	} else {
+		err = cur.All(context.TODO(), &result)
+		if err != nil {
+			log.Printf("Cursor error: %v\n", err)
+		}
+	}
+	return result
+}
@@ -0,0 +1,138 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+)
+
+//TxnContribution defines the transaction type of contributions to retirement and health savings accounts
+const TxnContribution string = "Contribution"
+
+//ContributionLimit holds the annual contribution limit for an account type
+type ContributionLimit struct {
+	UID        string             `json:"-"`
+	ID         primitive.ObjectID `json:"id" bson:"_id"`
+	Year       int32              `json:"year" bson:"year"`
+	Type       string             `json:"type" bson:"type"`
+	Limit      float64            `json:"limit" bson:"limit"`
+	CatchUp    float64            `json:"catchUp" bson:"catchUp"`
+	CatchUpAge int                `json:"catchUpAge" bson:"catchUpAge"`
+}
+
+//ContributionLimits holds an array of contribution limits.
+type ContributionLimits []*ContributionLimit
+
+//Contribution holds the contributions for a tax year against the limit of an account type
+type Contribution struct {
+	Year        int32      `json:"year"`
+	Type        string     `json:"type"`
+	Accounts    []string   `json:"accounts"`
+	Contributed float64    `json:"contributed"`
+	Limit       float64    `json:"limit"`
+	CatchUp     float64    `json:"catchUp"`
+	Remaining   float64    `json:"remaining"`
+	Excess      float64    `json:"excess"`
+	Deadline    *time.Time `json:"deadline"`
+	Warnings    []string   `json:"warnings"`
+	Activities  Activities `json:"activities"`
+}
+
+//Contributions holds an array of contributions.
+type Contributions []*Contribution
+
+func createContributionsIndices(ctx context.Context, limitsCol *mongo.Collection) {
+
+	keys := bsonx.Doc{{Key: "UID", Value: bsonx.Int32(1)}}
+	createIndex(ctx, limitsCol, "idx_UID", keys, false)
+
+	keys = bsonx.Doc{{Key: "year", Value: bsonx.Int32(1)}}
+	createIndex(ctx, limitsCol, "idx_year", keys, false)
+}
+
+//ContributionLimitsUpdate updates contribution limits
+func (mdb *MongoDB) ContributionLimitsUpdate(ctx context.Context, limits ContributionLimits) error {
+
+	user := UserFromCtx(ctx)
+	if len(limits) == 0 {
+		return nil
+	}
+
+	var operations []mongo.WriteModel
+	for _, limit := range limits {
+		if limit.ID.IsZero() {
+			limit.ID = primitive.NewObjectID()
+		}
+		limit.UID = user.UID
+		operation := mongo.NewUpdateManyModel()
+		operation.SetFilter(bson.M{"UID": limit.UID, "_id": limit.ID})
+		update := bson.M{"$set": limit}
+		operation.SetUpdate(update)
+		operation.SetUpsert(true)
+		operations = append(operations, operation)
+	}
+
+	bulkOption := options.BulkWriteOptions{}
+	bulkOption.SetOrdered(true)
+	col := mdb.db.Collection(CONTRIBLIMITScol)
+	_, err := col.BulkWrite(context.TODO(), operations, &bulkOption)
+	return err
+}
+
+//DeleteContributionLimit deletes the contribution limit for the id
+func (mdb *MongoDB) DeleteContributionLimit(ctx context.Context, id primitive.ObjectID) error {
+
+	user := UserFromCtx(ctx)
+	col := mdb.db.Collection(CONTRIBLIMITScol)
+	_, err := col.DeleteOne(ctx, bson.M{"UID": user.UID, "_id": id})
+	return err
+}
+
+//ContributionLimits returns the contribution limits for the year, or all years when year is 0
+func (mdb *MongoDB) ContributionLimits(ctx context.Context, year int32) ContributionLimits {
+
+	var options = options.Find()
+	var query map[string]interface{}
+	query = make(map[string]interface{})
+
+	user := UserFromCtx(ctx)
+	query["UID"] = bson.M{"$eq": user.UID}
+	if year > 0 {
+		query["year"] = bson.M{"$eq": year}
+	}
+	options.SetSort(bson.D{{"year", 1}, {"type", 1}})
+
+	var result ContributionLimits
+	col := mdb.db.Collection(CONTRIBLIMITScol)
+	cur, err := col.Find(context.TODO(), query, options)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+	} else {
+		err = cur.All(context.TODO(), &result)
+		if err != nil {
+			log.Printf("Cursor error: %v\n", err)
+		}
+	}
+	return result
+}
+
+//ContributionActivities returns the activities tagged as contributions for the date range sorted by date
+func (mdb *MongoDB) ContributionActivities(ctx context.Context, fromDate *time.Time, toDate *time.Time) Activities {
+
+	var options = options.Find()
+	var query map[string]interface{}
+	query = make(map[string]interface{})
+
+	user := UserFromCtx(ctx)
+	query["UID"] = bson.M{"$eq": user.UID}
+	query["txnType"] = bson.M{"$eq": TxnContribution}
+	query["date"] = bson.M{"$gte": fromDate, "$lte": toDate}
+	options.SetSort(bson.D{{"date", 1}})
+	return mdb.getActivities(query, options)
+}
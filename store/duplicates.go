@@ -0,0 +1,187 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rkapps/go_finance/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+)
+
+const (
+	//DupPending defines a duplicate pair waiting for review
+	DupPending string = "P"
+	//DupMerged defines a duplicate pair merged into the original activity
+	DupMerged string = "M"
+	//DupIgnored defines a pair that is not a duplicate
+	DupIgnored string = "I"
+)
+
+//ActvDuplicate holds a pair of activities suspected to be duplicates
+type ActvDuplicate struct {
+	UID       string             `json:"-"`
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Key       string             `json:"key" bson:"key"`
+	ActvID    primitive.ObjectID `json:"actvId" bson:"actvid"`
+	DupID     primitive.ObjectID `json:"dupId" bson:"dupid"`
+	Activity  *Activity          `json:"activity" bson:"activity"`
+	Duplicate *Activity          `json:"duplicate" bson:"duplicate"`
+	Status    string             `json:"status" bson:"status"`
+	Date      *time.Time         `json:"date" bson:"date"`
+}
+
+//ActvDuplicates holds an array of duplicate pairs.
+type ActvDuplicates []*ActvDuplicate
+
+func createActvDuplicatesIndices(ctx context.Context, col *mongo.Collection) {
+
+	keys := bsonx.Doc{
+		{Key: "UID", Value: bsonx.Int32(1)},
+		{Key: "key", Value: bsonx.Int32(1)},
+	}
+	createIndex(ctx, col, "idx_UID_key", keys, true)
+
+	keys = bsonx.Doc{{Key: "status", Value: bsonx.Int32(1)}}
+	createIndex(ctx, col, "idx_status", keys, false)
+}
+
+//Fingerprint returns a key identifying the activity regardless of its ID
+func (actv *Activity) Fingerprint() string {
+	date := ""
+	if actv.Date != nil {
+		date = utils.DateFormat1(*actv.Date)
+	}
+	return fmt.Sprintf("%s|%s|%s|%.2f|%s", actv.Account, date, actv.Dbcr, actv.Amount, utils.NormalizeMerchant(actv.Description))
+}
+
+//DuplicateKey returns the key of the pair, independent of the order of the activities
+func DuplicateKey(actv *Activity, dup *Activity) string {
+	keys := []string{actv.Fingerprint(), dup.Fingerprint()}
+	sort.Strings(keys)
+	return strings.Join(keys, "#")
+}
+
+//ActvDuplicatesUpdate adds the duplicate pairs, leaving the decision on existing pairs untouched
+func (mdb *MongoDB) ActvDuplicatesUpdate(ctx context.Context, dups ActvDuplicates) error {
+
+	user := UserFromCtx(ctx)
+	if len(dups) == 0 {
+		return nil
+	}
+
+	var operations []mongo.WriteModel
+
+	for _, dup := range dups {
+		dup.UID = user.UID
+		if dup.Date == nil {
+			now := time.Now()
+			dup.Date = &now
+		}
+		operation := mongo.NewUpdateOneModel()
+		operation.SetFilter(bson.M{"UID": dup.UID, "key": dup.Key})
+		update := bson.M{
+			"$set": bson.M{
+				"actvid":    dup.ActvID,
+				"dupid":     dup.DupID,
+				"activity":  dup.Activity,
+				"duplicate": dup.Duplicate,
+				"date":      dup.Date,
+			},
+			"$setOnInsert": bson.M{
+				"_id":    primitive.NewObjectID(),
+				"status": DupPending,
+			},
+		}
+		operation.SetUpdate(update)
+		operation.SetUpsert(true)
+		operations = append(operations, operation)
+	}
+
+	bulkOption := options.BulkWriteOptions{}
+	bulkOption.SetOrdered(true)
+	col := mdb.db.Collection(ACTVDUPScol)
+	_, err := col.BulkWrite(context.TODO(), operations, &bulkOption)
+	return err
+}
+
+//ActvDuplicatesByKeys returns the duplicate pairs for the keys
+func (mdb *MongoDB) ActvDuplicatesByKeys(ctx context.Context, keys []string) ActvDuplicates {
+
+	user := UserFromCtx(ctx)
+	if len(keys) == 0 {
+		return nil
+	}
+	query := bson.M{
+		"UID": bson.M{"$eq": user.UID},
+		"key": bson.M{"$in": keys},
+	}
+	return mdb.getActvDuplicates(query, nil)
+}
+
+//ActvDuplicates returns the duplicate pairs with the status
+func (mdb *MongoDB) ActvDuplicates(ctx context.Context, status string) ActvDuplicates {
+
+	var options = options.Find()
+	var query map[string]interface{}
+	query = make(map[string]interface{})
+
+	user := UserFromCtx(ctx)
+	query["UID"] = bson.M{"$eq": user.UID}
+	if len(status) > 0 {
+		query["status"] = bson.M{"$eq": status}
+	}
+
+	options.SetSort(bson.D{{"activity.date", -1}})
+	return mdb.getActvDuplicates(query, options)
+}
+
+//GetActvDuplicate returns the duplicate pair for the id
+func (mdb *MongoDB) GetActvDuplicate(ctx context.Context, id primitive.ObjectID) *ActvDuplicate {
+
+	user := UserFromCtx(ctx)
+	query := bson.M{"UID": user.UID, "_id": id}
+	dups := mdb.getActvDuplicates(query, nil)
+	if len(dups) > 0 {
+		return dups[0]
+	}
+	return nil
+}
+
+//ActvDuplicateUpdateStatus records the review decision on the pair
+func (mdb *MongoDB) ActvDuplicateUpdateStatus(ctx context.Context, id primitive.ObjectID, status string) error {
+
+	user := UserFromCtx(ctx)
+	col := mdb.db.Collection(ACTVDUPScol)
+	_, err := col.UpdateOne(ctx, bson.M{"UID": user.UID, "_id": id}, bson.M{"$set": bson.M{"status": status}})
+	return err
+}
+
+func (mdb *MongoDB) getActvDuplicates(query interface{}, ops *options.FindOptions) ActvDuplicates {
+
+	if ops == nil {
+		ops = options.Find()
+	}
+
+	var result ActvDuplicates
+
+	col := mdb.db.Collection(ACTVDUPScol)
+	cur, err := col.Find(context.TODO(), query, ops)
+
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+	} else {
+		err = cur.All(context.TODO(), &result)
+		if err != nil {
+			log.Printf("Cursor error: %v\n", err)
+		}
+	}
+	return result
+}
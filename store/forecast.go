@@ -0,0 +1,202 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+)
+
+//Budget holds the monthly amount budgeted for a group and category
+type Budget struct {
+	UID      string             `json:"-"`
+	ID       primitive.ObjectID `json:"id" bson:"_id"`
+	Group    string             `json:"group" bson:"group"`
+	Category string             `json:"category" bson:"category"`
+	Account  string             `json:"account" bson:"account"`
+	Amount   float64            `json:"amount" bson:"amount"`
+}
+
+//Budgets holds an array of budgets.
+type Budgets []*Budget
+
+//ScheduledItem holds a one-off income or expense expected on a date
+type ScheduledItem struct {
+	UID         string             `json:"-"`
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Date        *time.Time         `json:"date" bson:"date"`
+	Account     string             `json:"account" bson:"account"`
+	Description string             `json:"description" bson:"description"`
+	Dbcr        string             `json:"dbcr" bson:"dbcr"`
+	Amount      float64            `json:"amount" bson:"amount"`
+}
+
+//ScheduledItems holds an array of scheduled items.
+type ScheduledItems []*ScheduledItem
+
+//ForecastPoint holds the projected balance of an account after an item
+type ForecastPoint struct {
+	Date        *time.Time `json:"date"`
+	Description string     `json:"description"`
+	Source      string     `json:"source"`
+	Amount      float64    `json:"amount"`
+	Balance     float64    `json:"balance"`
+}
+
+//Forecast holds the projected balances of an account
+type Forecast struct {
+	Account      string           `json:"account"`
+	StartDate    *time.Time       `json:"startDate"`
+	StartBalance float64          `json:"startBalance"`
+	EndBalance   float64          `json:"endBalance"`
+	LowBalance   float64          `json:"lowBalance"`
+	LowDate      *time.Time       `json:"lowDate"`
+	Points       []*ForecastPoint `json:"points"`
+	Alerts       []*ForecastPoint `json:"alerts"`
+}
+
+//Forecasts holds an array of forecasts.
+type Forecasts []*Forecast
+
+func createForecastIndices(ctx context.Context, budgetsCol *mongo.Collection, scheduleCol *mongo.Collection) {
+
+	keys := bsonx.Doc{{Key: "UID", Value: bsonx.Int32(1)}}
+	createIndex(ctx, budgetsCol, "idx_UID", keys, false)
+
+	keys = bsonx.Doc{{Key: "UID", Value: bsonx.Int32(1)}}
+	createIndex(ctx, scheduleCol, "idx_UID", keys, false)
+
+	keys = bsonx.Doc{{Key: "date", Value: bsonx.Int32(1)}}
+	createIndex(ctx, scheduleCol, "idx_date", keys, false)
+}
+
+//BudgetsUpdate updates budgets
+func (mdb *MongoDB) BudgetsUpdate(ctx context.Context, budgets Budgets) error {
+
+	user := UserFromCtx(ctx)
+	if len(budgets) == 0 {
+		return nil
+	}
+
+	var operations []mongo.WriteModel
+	for _, budget := range budgets {
+		if budget.ID.IsZero() {
+			budget.ID = primitive.NewObjectID()
+		}
+		budget.UID = user.UID
+		operation := mongo.NewUpdateManyModel()
+		operation.SetFilter(bson.M{"UID": budget.UID, "_id": budget.ID})
+		update := bson.M{"$set": budget}
+		operation.SetUpdate(update)
+		operation.SetUpsert(true)
+		operations = append(operations, operation)
+	}
+
+	bulkOption := options.BulkWriteOptions{}
+	bulkOption.SetOrdered(true)
+	col := mdb.db.Collection(BUDGETScol)
+	_, err := col.BulkWrite(context.TODO(), operations, &bulkOption)
+	return err
+}
+
+//DeleteBudget deletes the budget for the id
+func (mdb *MongoDB) DeleteBudget(ctx context.Context, id primitive.ObjectID) error {
+
+	user := UserFromCtx(ctx)
+	col := mdb.db.Collection(BUDGETScol)
+	_, err := col.DeleteOne(ctx, bson.M{"UID": user.UID, "_id": id})
+	return err
+}
+
+//Budgets returns the budgets
+func (mdb *MongoDB) Budgets(ctx context.Context) Budgets {
+
+	var options = options.Find()
+	user := UserFromCtx(ctx)
+	query := bson.M{"UID": bson.M{"$eq": user.UID}}
+	options.SetSort(bson.D{{"group", 1}, {"category", 1}})
+
+	var result Budgets
+	col := mdb.db.Collection(BUDGETScol)
+	cur, err := col.Find(context.TODO(), query, options)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+	} else {
+		err = cur.All(context.TODO(), &result)
+		if err != nil {
+			log.Printf("Cursor error: %v\n", err)
+		}
+	}
+	return result
+}
+
+//ScheduledItemsUpdate updates scheduled items
+func (mdb *MongoDB) ScheduledItemsUpdate(ctx context.Context, items ScheduledItems) error {
+
+	user := UserFromCtx(ctx)
+	if len(items) == 0 {
+		return nil
+	}
+
+	var operations []mongo.WriteModel
+	for _, item := range items {
+		if item.ID.IsZero() {
+			item.ID = primitive.NewObjectID()
+		}
+		item.UID = user.UID
+		operation := mongo.NewUpdateManyModel()
+		operation.SetFilter(bson.M{"UID": item.UID, "_id": item.ID})
+		update := bson.M{"$set": item}
+		operation.SetUpdate(update)
+		operation.SetUpsert(true)
+		operations = append(operations, operation)
+	}
+
+	bulkOption := options.BulkWriteOptions{}
+	bulkOption.SetOrdered(true)
+	col := mdb.db.Collection(SCHEDULEcol)
+	_, err := col.BulkWrite(context.TODO(), operations, &bulkOption)
+	return err
+}
+
+//DeleteScheduledItem deletes the scheduled item for the id
+func (mdb *MongoDB) DeleteScheduledItem(ctx context.Context, id primitive.ObjectID) error {
+
+	user := UserFromCtx(ctx)
+	col := mdb.db.Collection(SCHEDULEcol)
+	_, err := col.DeleteOne(ctx, bson.M{"UID": user.UID, "_id": id})
+	return err
+}
+
+//ScheduledItems returns the scheduled items from the date sorted by date
+func (mdb *MongoDB) ScheduledItems(ctx context.Context, fromDate *time.Time) ScheduledItems {
+
+	var options = options.Find()
+	var query map[string]interface{}
+	query = make(map[string]interface{})
+
+	user := UserFromCtx(ctx)
+	query["UID"] = bson.M{"$eq": user.UID}
+	if fromDate != nil {
+		query["date"] = bson.M{"$gte": fromDate}
+	}
+	options.SetSort(bson.D{{"date", 1}})
+
+	var result ScheduledItems
+	col := mdb.db.Collection(SCHEDULEcol)
+	cur, err := col.Find(context.TODO(), query, options)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+	} else {
+		err = cur.All(context.TODO(), &result)
+		if err != nil {
+			log.Printf("Cursor error: %v\n", err)
+		}
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+)
+
+//FXRate holds the value in USD of one unit of the currency at the close of the day
+type FXRate struct {
+	Currency string    `json:"currency" bson:"currency"`
+	Date     time.Time `json:"date" bson:"date"`
+	Rate     float64   `json:"rate" bson:"rate"`
+}
+
+//FXRates holds an array of exchange rates.
+type FXRates []*FXRate
+
+func createFXRatesIndices(ctx context.Context, col *mongo.Collection) {
+
+	keys := bsonx.Doc{{Key: "currency", Value: bsonx.Int32(1)}, {Key: "date", Value: bsonx.Int32(1)}}
+	createIndex(ctx, col, "idx_currency_date", keys, true)
+}
+
+//FXRatesUpdate adds or replaces the rates of the currencies for the days
+func (mdb *MongoDB) FXRatesUpdate(ctx context.Context, rates FXRates) error {
+
+	if len(rates) == 0 {
+		return nil
+	}
+
+	var operations []mongo.WriteModel
+	for _, rate := range rates {
+		operation := mongo.NewUpdateManyModel()
+		operation.SetFilter(bson.M{"currency": rate.Currency, "date": rate.Date})
+		update := bson.M{"$set": rate}
+		operation.SetUpdate(update)
+		operation.SetUpsert(true)
+		operations = append(operations, operation)
+	}
+
+	bulkOption := options.BulkWriteOptions{}
+	bulkOption.SetOrdered(false)
+	col := mdb.db.Collection(FXRATEScol)
+	_, err := col.BulkWrite(context.TODO(), operations, &bulkOption)
+	return err
+}
+
+//GetFXRates returns the rates of the currency sorted by date
+func (mdb *MongoDB) GetFXRates(ctx context.Context, currency string) FXRates {
+
+	var options = options.Find()
+	options.SetSort(bson.D{{"date", 1}})
+
+	var result FXRates
+	col := mdb.db.Collection(FXRATEScol)
+	cur, err := col.Find(context.TODO(), bson.M{"currency": strings.ToUpper(currency)}, options)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+	} else {
+		err = cur.All(context.TODO(), &result)
+		if err != nil {
+			log.Printf("Cursor error: %v\n", err)
+		}
+	}
+	return result
+}
+
+//LastFXRate returns the latest rate of the currency, or nil when there is none
+func (mdb *MongoDB) LastFXRate(ctx context.Context, currency string) *FXRate {
+
+	rate := &FXRate{}
+	col := mdb.db.Collection(FXRATEScol)
+	opts := options.FindOne().SetSort(bson.D{{"date", -1}})
+	err := col.FindOne(ctx, bson.M{"currency": strings.ToUpper(currency)}, opts).Decode(rate)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error: %v\n", err)
+		}
+		return nil
+	}
+	return rate
+}
+
+//FXCurrencies returns the currencies of the accounts, activities, tickers, balances and reporting currencies of all users
+func (mdb *MongoDB) FXCurrencies(ctx context.Context) []string {
+
+	fields := map[string]string{ACCOUNTScol: "currency", ACTVScol: "currency", TICKERScol: "currency", BALANCEScol: "currency",
+		SETTINGScol: "reportingCurrency"}
+
+	var currencies []string
+	cm := make(map[string]bool)
+	for colName, field := range fields {
+		values, err := mdb.db.Collection(colName).Distinct(ctx, field, bson.M{})
+		if err != nil {
+			log.Printf("Distinct error: %v", err)
+			continue
+		}
+		for _, value := range values {
+			if currency, ok := value.(string); ok && len(currency) > 0 && !cm[strings.ToUpper(currency)] {
+				cm[strings.ToUpper(currency)] = true
+				currencies = append(currencies, strings.ToUpper(currency))
+			}
+		}
+	}
+	return currencies
+}
@@ -0,0 +1,41 @@
+package store
+
+import "time"
+
+//WashSaleBuy holds a purchase of the symbol that conflicts with harvesting the loss
+type WashSaleBuy struct {
+	Account string     `json:"account"`
+	Date    *time.Time `json:"date"`
+	Qty     float64    `json:"qty"`
+	Price   float64    `json:"price"`
+}
+
+//HarvestLot holds an open lot with an unrealized loss and the tax saved by selling it
+type HarvestLot struct {
+	Group        string         `json:"group"`
+	Category     string         `json:"category"`
+	Account      string         `json:"account"`
+	Symbol       string         `json:"symbol"`
+	Date         *time.Time     `json:"date"`
+	Qty          float64        `json:"qty"`
+	Cost         float64        `json:"cost"`
+	CostValue    float64        `json:"costValue"`
+	PrLast       float64        `json:"prLast"`
+	MktValue     float64        `json:"mktValue"`
+	Loss         float64        `json:"loss"`
+	LossPerc     float64        `json:"lossPerc"`
+	Term         string         `json:"term"`
+	TaxRate      float64        `json:"taxRate"`
+	TaxSavings   float64        `json:"taxSavings"`
+	WashSale     bool           `json:"washSale"`
+	WashSaleBuys []*WashSaleBuy `json:"washSaleBuys"`
+	Replacements []string       `json:"replacements"`
+}
+
+//Harvest holds the lots to harvest and the total losses and tax savings
+type Harvest struct {
+	ShortTermLoss float64       `json:"shortTermLoss"`
+	LongTermLoss  float64       `json:"longTermLoss"`
+	TaxSavings    float64       `json:"taxSavings"`
+	Lots          []*HarvestLot `json:"lots"`
+}
@@ -14,36 +14,40 @@ import (
 
 //InvLot represents a security lot
 type InvLot struct {
-	UID         string             `json:"-"`
-	ID          primitive.ObjectID `bson:"_id"`
-	ActvID      primitive.ObjectID `bson:"actvid"`
-	Group       string             `json:"group" bson:"group"`
-	Category    string             `json:"category" bson:"category"`
-	Account     string             `json:"account" bson:"account"`
-	Symbol      string             `json:"symbol" bson:"symbol"`
-	Date        *time.Time         `json:"date" bson:"date"`
-	TxnType     string             `json:"txnType" bson:"txnType"`
-	TxnDate     *time.Time         `json:"txnDate" bson:"txnDate"`
-	Status      string             `json:"status" bson:"status"`
-	OrigQty     float64            `json:"origQty" bson:"origQty"`
-	Qty         float64            `json:"qty" bson:"qty"`
-	Cost        float64            `json:"cost" bson:"cost"`
-	CostValue   float64            `json:"costValue"`
-	SendQty     float64            `json:"sendQty" bson:"sendQty"`
-	SendDate    *time.Time         `json:"sendDate" bson:"sendDate"`
-	OrigAccount string             `json:"origAccount" bson:"origAccount"`
-	SaleQty     float64            `json:"saleQty" bson:"saleQty"`
-	SaleDate    *time.Time         `json:"saleDate" bson:"saleDate"`
-	SalePrice   float64            `json:"salePrice" bson:"salePrice"`
-	SaleValue   float64            `json:"saleValue"`
-	Fee         float64            `bson:"fee"`
-	PrLast      float64            `json:"prLast"`
-	PrDiffAmt   float64            `json:"prDiffAmt"`
-	PrDiffPerc  float64            `json:"prDiffPerc"`
-	MktValue    float64            `json:"mktValue"`
-	Dglamount   float64            `json:"dglAmount"`
-	Glamount    float64            `json:"glAmount"`
-	Glperc      float64            `json:"glPerc"`
+	UID          string             `json:"-"`
+	ID           primitive.ObjectID `bson:"_id"`
+	ActvID       primitive.ObjectID `bson:"actvid"`
+	Group        string             `json:"group" bson:"group"`
+	Category     string             `json:"category" bson:"category"`
+	Account      string             `json:"account" bson:"account"`
+	Symbol       string             `json:"symbol" bson:"symbol"`
+	TaxTreatment string             `json:"taxTreatment" bson:"-"`
+	Currency     string             `json:"currency" bson:"currency,omitempty"`
+	PrCurrency   string             `json:"-" bson:"-"`
+	Date         *time.Time         `json:"date" bson:"date"`
+	TxnType      string             `json:"txnType" bson:"txnType"`
+	TxnDate      *time.Time         `json:"txnDate" bson:"txnDate"`
+	Status       string             `json:"status" bson:"status"`
+	OrigQty      float64            `json:"origQty" bson:"origQty"`
+	Qty          float64            `json:"qty" bson:"qty"`
+	Cost         float64            `json:"cost" bson:"cost"`
+	CostValue    float64            `json:"costValue"`
+	SendQty      float64            `json:"sendQty" bson:"sendQty"`
+	SendDate     *time.Time         `json:"sendDate" bson:"sendDate"`
+	OrigAccount  string             `json:"origAccount" bson:"origAccount"`
+	RecvDate     *time.Time         `json:"recvDate" bson:"recvDate"`
+	SaleQty      float64            `json:"saleQty" bson:"saleQty"`
+	SaleDate     *time.Time         `json:"saleDate" bson:"saleDate"`
+	SalePrice    float64            `json:"salePrice" bson:"salePrice"`
+	SaleValue    float64            `json:"saleValue"`
+	Fee          float64            `bson:"fee"`
+	PrLast       float64            `json:"prLast"`
+	PrDiffAmt    float64            `json:"prDiffAmt"`
+	PrDiffPerc   float64            `json:"prDiffPerc"`
+	MktValue     float64            `json:"mktValue"`
+	Dglamount    float64            `json:"dglAmount"`
+	Glamount     float64            `json:"glAmount"`
+	Glperc       float64            `json:"glPerc"`
 }
 
 //InvLots holds an array of invsale.
@@ -66,22 +70,14 @@ type InvHolding struct {
 	Dglamount  float64       `json:"dglAmount"`
 	Glamount   float64       `json:"glAmount"`
 	Glperc     float64       `json:"glPerc"`
+	Currency   string        `json:"currency"`
+	Risk       *RiskMetrics  `json:"risk,omitempty"`
 	Holdings   []*InvHolding `json:"holdings"`
 }
 
 //InvHoldings holds an array of holding.
 type InvHoldings []*InvHolding
 
-//InvAccount holds accounts by group and category
-type InvAccount struct {
-	Group    string `json:"group"`
-	Category string `json:"category"`
-	Account  string `json:"account"`
-}
-
-//InvAccounts holds an array of accounts.
-type InvAccounts []*InvAccount
-
 func createInvLotIndices(ctx context.Context, col *mongo.Collection) {
 
 	keys := bsonx.Doc{{Key: "UID", Value: bsonx.Int32(1)}}
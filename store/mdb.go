@@ -28,6 +28,36 @@ const (
 
 	//TNEWScol is the collection tickets
 	TNEWScol = "tnews"
+
+	//ACTVDUPScol is the collection of suspected duplicate activities
+	ACTVDUPScol = "actvdup"
+
+	//BALANCEScol is the collection of account balances and valuations
+	BALANCEScol = "balance"
+
+	//BUDGETScol is the collection of monthly budgets
+	BUDGETScol = "budget"
+
+	//SCHEDULEcol is the collection of scheduled one-off items
+	SCHEDULEcol = "schedule"
+
+	//ACCOUNTScol is the collection of investment accounts
+	ACCOUNTScol = "account"
+
+	//CONTRIBLIMITScol is the collection of annual contribution limits
+	CONTRIBLIMITScol = "contriblimit"
+
+	//SETTINGScol is the collection of user settings
+	SETTINGScol = "settings"
+
+	//SNAPSHOTScol is the collection of daily snapshots of the holdings
+	SNAPSHOTScol = "snapshot"
+
+	//TARGETScol is the collection of target allocations
+	TARGETScol = "target"
+
+	//FXRATEScol is the collection of daily exchange rates
+	FXRATEScol = "fxrate"
 )
 
 //MongoDB defines the structure for the database
@@ -57,6 +87,14 @@ func NewMongoDB(connStr string) (*MongoDB, error) {
 	createTickerNewsIndices(ctx, db.Collection(TNEWScol))
 	createActivitiesIndices(ctx, db.Collection(ACTVScol))
 	createInvLotIndices(ctx, db.Collection(INVLOTScol))
+	createActvDuplicatesIndices(ctx, db.Collection(ACTVDUPScol))
+	createBalancesIndices(ctx, db.Collection(BALANCEScol))
+	createForecastIndices(ctx, db.Collection(BUDGETScol), db.Collection(SCHEDULEcol))
+	createAccountsIndices(ctx, db.Collection(ACCOUNTScol))
+	createContributionsIndices(ctx, db.Collection(CONTRIBLIMITScol))
+	createSnapshotsIndices(ctx, db.Collection(SNAPSHOTScol))
+	createTargetsIndices(ctx, db.Collection(TARGETScol))
+	createFXRatesIndices(ctx, db.Collection(FXRATEScol))
 
 	mdb := &MongoDB{client: client, ctx: ctx, db: db}
 
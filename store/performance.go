@@ -0,0 +1,37 @@
+package store
+
+import "time"
+
+//PerfReturn holds the returns for a performance period
+type PerfReturn struct {
+	Period     string             `json:"period"`
+	StartDate  *time.Time         `json:"startDate"`
+	StartValue float64            `json:"startValue"`
+	EndValue   float64            `json:"endValue"`
+	NetFlows   float64            `json:"netFlows"`
+	Gain       float64            `json:"gain"`
+	TWR        float64            `json:"twr"`
+	XIRR       float64            `json:"xirr"`
+	Benchmarks []*BenchmarkReturn `json:"benchmarks,omitempty"`
+}
+
+//BenchmarkReturn holds the return of a benchmark for a period and how the portfolio tracked it
+type BenchmarkReturn struct {
+	Symbol        string  `json:"symbol"`
+	Return        float64 `json:"return"`
+	Alpha         float64 `json:"alpha"`
+	Beta          float64 `json:"beta"`
+	TrackingError float64 `json:"trackingError"`
+}
+
+//Performance holds the returns of the portfolio, a group or an account
+type Performance struct {
+	Level    string        `json:"level"`
+	Name     string        `json:"name"`
+	Value    float64       `json:"value"`
+	Currency string        `json:"currency"`
+	Returns  []*PerfReturn `json:"returns"`
+}
+
+//Performances holds an array of performance.
+type Performances []*Performance
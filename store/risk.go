@@ -0,0 +1,50 @@
+package store
+
+import "time"
+
+//RiskMetrics holds the risk of the portfolio or a holding over a period
+type RiskMetrics struct {
+	Period       string  `json:"period"`
+	Benchmark    string  `json:"benchmark"`
+	Days         int     `json:"days"`
+	Volatility   float64 `json:"volatility"`
+	MaxDrawdown  float64 `json:"maxDrawdown"`
+	DrawdownDays int     `json:"drawdownDays"`
+	Sharpe       float64 `json:"sharpe"`
+	Sortino      float64 `json:"sortino"`
+	Beta         float64 `json:"beta"`
+}
+
+//InvRisk holds the risk of the portfolio and its holdings
+type InvRisk struct {
+	Period       string       `json:"period"`
+	Benchmark    string       `json:"benchmark"`
+	RiskFreeRate float64      `json:"riskFreeRate"`
+	Portfolio    *RiskMetrics `json:"portfolio"`
+	Holdings     InvHoldings  `json:"holdings"`
+}
+
+//CorrelationPoint holds the correlation of the returns in the window ending on the date
+type CorrelationPoint struct {
+	Date  *time.Time `json:"date"`
+	Value float64    `json:"value"`
+}
+
+//RollingCorrelation holds the rolling correlation of a pair of symbols
+type RollingCorrelation struct {
+	Symbol1 string              `json:"symbol1"`
+	Symbol2 string              `json:"symbol2"`
+	Points  []*CorrelationPoint `json:"points"`
+}
+
+//Correlation holds the correlation matrix of the returns of the symbols over the lookback period.
+//Observations holds the number of common returns of each pair, the correlation is 0 when fewer than 3.
+type Correlation struct {
+	Symbols      []string              `json:"symbols"`
+	Lookback     string                `json:"lookback"`
+	Frequency    string                `json:"frequency"`
+	Window       int                   `json:"window"`
+	Matrix       [][]float64           `json:"matrix"`
+	Observations [][]int               `json:"observations"`
+	Rolling      []*RollingCorrelation `json:"rolling"`
+}
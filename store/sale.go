@@ -0,0 +1,32 @@
+package store
+
+import "time"
+
+//SaleLot holds the part of a lot relieved by a planned sale
+type SaleLot struct {
+	Date      *time.Time `json:"date"`
+	Qty       float64    `json:"qty"`
+	Cost      float64    `json:"cost"`
+	CostValue float64    `json:"costValue"`
+	SaleValue float64    `json:"saleValue"`
+	Gain      float64    `json:"gain"`
+	Term      string     `json:"term"`
+}
+
+//SaleProjection holds the lots relieved by a planned sale, the realized gains by term and the estimated tax
+type SaleProjection struct {
+	Account          string     `json:"account"`
+	Symbol           string     `json:"symbol"`
+	TaxTreatment     string     `json:"taxTreatment"`
+	CostBasis        string     `json:"costBasis"`
+	Qty              float64    `json:"qty"`
+	Price            float64    `json:"price"`
+	Proceeds         float64    `json:"proceeds"`
+	CostValue        float64    `json:"costValue"`
+	ShortTermGain    float64    `json:"shortTermGain"`
+	LongTermGain     float64    `json:"longTermGain"`
+	ShortTermTaxRate float64    `json:"shortTermTaxRate"`
+	LongTermTaxRate  float64    `json:"longTermTaxRate"`
+	Tax              float64    `json:"tax"`
+	Lots             []*SaleLot `json:"lots"`
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+//UserSettings holds the preferences and personal details of the user
+type UserSettings struct {
+	UID               string     `json:"-" bson:"_id"`
+	BirthDate         *time.Time `json:"birthDate" bson:"birthDate"`
+	Benchmarks        []string   `json:"benchmarks" bson:"benchmarks"`
+	RiskFreeRate      float64    `json:"riskFreeRate" bson:"riskFreeRate"`
+	DriftBand         float64    `json:"driftBand" bson:"driftBand"`
+	ShortTermTaxRate  float64    `json:"shortTermTaxRate" bson:"shortTermTaxRate"`
+	LongTermTaxRate   float64    `json:"longTermTaxRate" bson:"longTermTaxRate"`
+	ReportingCurrency string     `json:"reportingCurrency" bson:"reportingCurrency"`
+}
+
+//GetUserSettings returns the settings of the user
+func (mdb *MongoDB) GetUserSettings(ctx context.Context) *UserSettings {
+
+	user := UserFromCtx(ctx)
+	settings := &UserSettings{}
+	col := mdb.db.Collection(SETTINGScol)
+	err := col.FindOne(ctx, bson.M{"_id": user.UID}).Decode(settings)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Error: %v\n", err)
+		}
+		settings.UID = user.UID
+	}
+	return settings
+}
+
+//UserSettingsUpdate updates the settings of the user
+func (mdb *MongoDB) UserSettingsUpdate(ctx context.Context, settings *UserSettings) error {
+
+	user := UserFromCtx(ctx)
+	settings.UID = user.UID
+	col := mdb.db.Collection(SETTINGScol)
+	_, err := col.UpdateOne(ctx, bson.M{"_id": settings.UID}, bson.M{"$set": settings}, options.Update().SetUpsert(true))
+	return err
+}
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+)
+
+//SnapshotHolding holds a holding of a symbol in an account on the day of the snapshot
+type SnapshotHolding struct {
+	Group     string  `json:"group" bson:"group"`
+	Category  string  `json:"category" bson:"category"`
+	Account   string  `json:"account" bson:"account"`
+	Symbol    string  `json:"symbol" bson:"symbol"`
+	Qty       float64 `json:"qty" bson:"qty"`
+	Price     float64 `json:"price" bson:"price"`
+	MktValue  float64 `json:"mktValue" bson:"mktValue"`
+	CostValue float64 `json:"costValue" bson:"costValue"`
+}
+
+//Snapshot holds the value of the holdings of a user at the end of a day in the currency
+type Snapshot struct {
+	UID       string             `json:"-"`
+	ID        primitive.ObjectID `json:"-" bson:"_id"`
+	Date      *time.Time         `json:"date" bson:"date"`
+	MktValue  float64            `json:"mktValue" bson:"mktValue"`
+	CostValue float64            `json:"costValue" bson:"costValue"`
+	Currency  string             `json:"currency" bson:"currency"`
+	Holdings  []*SnapshotHolding `json:"holdings,omitempty" bson:"holdings"`
+}
+
+//Snapshots holds an array of snapshots.
+type Snapshots []*Snapshot
+
+func createSnapshotsIndices(ctx context.Context, col *mongo.Collection) {
+
+	keys := bsonx.Doc{{Key: "UID", Value: bsonx.Int32(1)}, {Key: "date", Value: bsonx.Int32(1)}}
+	createIndex(ctx, col, "idx_UID_date", keys, true)
+}
+
+//SnapshotUpdate adds or replaces the snapshot of the user for the day
+func (mdb *MongoDB) SnapshotUpdate(ctx context.Context, snap *Snapshot) error {
+
+	user := UserFromCtx(ctx)
+	snap.UID = user.UID
+	if snap.ID.IsZero() {
+		snap.ID = primitive.NewObjectID()
+	}
+
+	update := bson.M{"$set": bson.M{"mktValue": snap.MktValue, "costValue": snap.CostValue, "currency": snap.Currency, "holdings": snap.Holdings},
+		"$setOnInsert": bson.M{"_id": snap.ID}}
+	col := mdb.db.Collection(SNAPSHOTScol)
+	_, err := col.UpdateOne(ctx, bson.M{"UID": snap.UID, "date": snap.Date}, update, options.Update().SetUpsert(true))
+	return err
+}
+
+//Snapshots returns the snapshots for the date range sorted by date, with the holdings when holdings is set
+func (mdb *MongoDB) Snapshots(ctx context.Context, fromDate *time.Time, toDate *time.Time, holdings bool) Snapshots {
+
+	var options = options.Find()
+	var query map[string]interface{}
+	query = make(map[string]interface{})
+
+	user := UserFromCtx(ctx)
+	query["UID"] = bson.M{"$eq": user.UID}
+	if fromDate != nil && toDate != nil {
+		query["date"] = bson.M{"$gte": fromDate, "$lte": toDate}
+	} else if fromDate != nil {
+		query["date"] = bson.M{"$gte": fromDate}
+	} else if toDate != nil {
+		query["date"] = bson.M{"$lte": toDate}
+	}
+	if !holdings {
+		options.SetProjection(bson.M{"holdings": 0})
+	}
+	options.SetSort(bson.D{{"date", 1}})
+
+	var result Snapshots
+	col := mdb.db.Collection(SNAPSHOTScol)
+	cur, err := col.Find(context.TODO(), query, options)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+	} else {
+		err = cur.All(context.TODO(), &result)
+		if err != nil {
+			log.Printf("Cursor error: %v\n", err)
+		}
+	}
+	return result
+}
+
+//InvestmentsUsers returns the users with investment lots
+func (mdb *MongoDB) InvestmentsUsers(ctx context.Context) ([]string, error) {
+
+	col := mdb.db.Collection(INVLOTScol)
+	values, err := col.Distinct(ctx, "UID", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	var uids []string
+	for _, value := range values {
+		if uid, ok := value.(string); ok && len(uid) > 0 {
+			uids = append(uids, uid)
+		}
+	}
+	return uids, nil
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"log"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/x/bsonx"
+)
+
+//AllocTarget holds the target weight in percent of a symbol, an asset class or a sector
+type AllocTarget struct {
+	UID       string             `json:"-"`
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	Dimension string             `json:"dimension" bson:"dimension"`
+	Name      string             `json:"name" bson:"name"`
+	Weight    float64            `json:"weight" bson:"weight"`
+}
+
+//AllocTargets holds an array of target.
+type AllocTargets []*AllocTarget
+
+//RebalanceDrift holds the current and target weights of a slice and the trade that rebalances it
+type RebalanceDrift struct {
+	Dimension   string  `json:"dimension"`
+	Name        string  `json:"name"`
+	Target      float64 `json:"target"`
+	Current     float64 `json:"current"`
+	Drift       float64 `json:"drift"`
+	MktValue    float64 `json:"mktValue"`
+	TargetValue float64 `json:"targetValue"`
+	Trade       float64 `json:"trade"`
+	OutOfBand   bool    `json:"outOfBand"`
+}
+
+//RebalanceTrade holds a suggested trade and the gains realized by a sale
+type RebalanceTrade struct {
+	Account       string  `json:"account"`
+	TaxTreatment  string  `json:"taxTreatment"`
+	Symbol        string  `json:"symbol"`
+	TxnType       string  `json:"txnType"`
+	Qty           float64 `json:"qty"`
+	Price         float64 `json:"price"`
+	Amount        float64 `json:"amount"`
+	ShortTermGain float64 `json:"shortTermGain"`
+	LongTermGain  float64 `json:"longTermGain"`
+}
+
+//Rebalance holds the drift of the holdings from the targets and the trades that rebalance them
+type Rebalance struct {
+	Dimension     string            `json:"dimension"`
+	DriftBand     float64           `json:"driftBand"`
+	MktValue      float64           `json:"mktValue"`
+	ShortTermGain float64           `json:"shortTermGain"`
+	LongTermGain  float64           `json:"longTermGain"`
+	Drifts        []*RebalanceDrift `json:"drifts"`
+	Trades        []*RebalanceTrade `json:"trades"`
+	Warnings      []string          `json:"warnings"`
+}
+
+func createTargetsIndices(ctx context.Context, col *mongo.Collection) {
+
+	keys := bsonx.Doc{{Key: "UID", Value: bsonx.Int32(1)}, {Key: "dimension", Value: bsonx.Int32(1)}, {Key: "name", Value: bsonx.Int32(1)}}
+	createIndex(ctx, col, "idx_UID_dimension_name", keys, true)
+}
+
+//AllocTargetsUpdate updates target allocations
+func (mdb *MongoDB) AllocTargetsUpdate(ctx context.Context, targets AllocTargets) error {
+
+	user := UserFromCtx(ctx)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	var operations []mongo.WriteModel
+	for _, target := range targets {
+		if target.ID.IsZero() {
+			target.ID = primitive.NewObjectID()
+		}
+		target.UID = user.UID
+		operation := mongo.NewUpdateManyModel()
+		operation.SetFilter(bson.M{"UID": target.UID, "_id": target.ID})
+		update := bson.M{"$set": target}
+		operation.SetUpdate(update)
+		operation.SetUpsert(true)
+		operations = append(operations, operation)
+	}
+
+	bulkOption := options.BulkWriteOptions{}
+	bulkOption.SetOrdered(true)
+	col := mdb.db.Collection(TARGETScol)
+	_, err := col.BulkWrite(context.TODO(), operations, &bulkOption)
+	return err
+}
+
+//DeleteAllocTarget deletes the target allocation for the id
+func (mdb *MongoDB) DeleteAllocTarget(ctx context.Context, id primitive.ObjectID) error {
+
+	user := UserFromCtx(ctx)
+	col := mdb.db.Collection(TARGETScol)
+	_, err := col.DeleteOne(ctx, bson.M{"UID": user.UID, "_id": id})
+	return err
+}
+
+//AllocTargets returns the target allocations for the dimension, or all dimensions when not set
+func (mdb *MongoDB) AllocTargets(ctx context.Context, dimension string) AllocTargets {
+
+	var options = options.Find()
+	var query map[string]interface{}
+	query = make(map[string]interface{})
+
+	user := UserFromCtx(ctx)
+	query["UID"] = bson.M{"$eq": user.UID}
+	if len(dimension) > 0 {
+		query["dimension"] = bson.M{"$eq": dimension}
+	}
+	options.SetSort(bson.D{{"dimension", 1}, {"name", 1}})
+
+	var result AllocTargets
+	col := mdb.db.Collection(TARGETScol)
+	cur, err := col.Find(context.TODO(), query, options)
+	if err != nil {
+		log.Printf("Error: %v\n", err)
+	} else {
+		err = cur.All(context.TODO(), &result)
+		if err != nil {
+			log.Printf("Cursor error: %v\n", err)
+		}
+	}
+	return result
+}
@@ -38,6 +38,19 @@ const (
 	EMA string = "EMA"
 	//RSI defines the string RSI
 	RSI string = "RSI"
+
+	//AssetStock defines the stock asset class
+	AssetStock string = "stock"
+	//AssetETF defines the exchange traded fund asset class
+	AssetETF string = "etf"
+	//AssetCrypto defines the cryptocurrency asset class
+	AssetCrypto string = "crypto"
+	//AssetMutf defines the mutual fund asset class
+	AssetMutf string = "mutual fund"
+	//AssetIndex defines the index asset class
+	AssetIndex string = "index"
+	//AssetOther defines the asset class of tickers on other exchanges
+	AssetOther string = "other"
 )
 
 var (
@@ -58,6 +71,8 @@ type Ticker struct {
 	Name        string                        `json:"name" bson:"name"`
 	Sector      string                        `json:"sector" bson:"sector"`
 	Industry    string                        `json:"industry" bson:"industry"`
+	AssetType   string                        `json:"assetType" bson:"assetType,omitempty"`
+	Currency    string                        `json:"currency" bson:"currency,omitempty"`
 	Overview    string                        `json:"overview" bson:"overview"`
 	MarketCap   int                           `json:"marketCap" bson:"marketCap"`
 	Volume      int                           `json:"volume" bson:"volume"`
@@ -82,6 +97,7 @@ type Ticker struct {
 	PrPrev      float64                       `json:"prPrev" bson:"prPrev"`
 	PrDiffAmt   float64                       `json:"prDiffAmt" bson:"prDiffAmt"`
 	PrDiffPerc  float64                       `json:"prDiffPerc" bson:"prDiffPerc"`
+	PrSource    string                        `json:"prSource" bson:"prSource"`
 	Pr52WkHigh  float64                       `json:"pr52WkHigh" bson:"pr52WkHigh"`
 	Pr52WkLow   float64                       `json:"pr52WkLow" bsone:"pr52WkLow"`
 	Performance map[string]map[string]float64 `json:"performance" bson:"performance"`
@@ -239,6 +255,36 @@ func (t Ticker) IsStock() bool {
 		strings.Compare(t.Exchange, ExOtc) == 0
 }
 
+//AssetClass returns the asset class derived from the exchange and the asset type of the fundamentals.
+//Without an asset type, tickers listed on NYSEARCA or named as an ETF are treated as ETFs.
+func (t Ticker) AssetClass() string {
+	switch {
+	case t.IsCrypto():
+		return AssetCrypto
+	case t.IsMutf():
+		return AssetMutf
+	case t.IsIndex():
+		return AssetIndex
+	case t.IsETF():
+		return AssetETF
+	case t.IsStock():
+		return AssetStock
+	}
+	return AssetOther
+}
+
+//IsETF returns true if the asset type of the listed ticker is ETF. Tickers without an asset type
+//are ETFs when listed on NYSEARCA or named as an ETF.
+func (t Ticker) IsETF() bool {
+	if !t.IsStock() {
+		return false
+	}
+	if len(t.AssetType) > 0 {
+		return strings.EqualFold(t.AssetType, "ETF")
+	}
+	return strings.Compare(t.Exchange, ExNyseArca) == 0 || utils.Contains(strings.Fields(strings.ToUpper(t.Name)), "ETF")
+}
+
 //SetPriceDiff sets the price difference between the last price and previous price
 func (t *Ticker) SetPriceDiff() {
 
@@ -584,6 +630,7 @@ func (mdb *MongoDB) UpdateTickersRealtime(ctx context.Context, tickers Tickers)
 			"prPrev":     ticker.PrPrev,
 			"prDiffAmt":  ticker.PrDiffAmt,
 			"prDiffPerc": ticker.PrDiffPerc,
+			"prSource":   ticker.PrSource,
 		},
 		}
 
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // //RTransaction holds metadata for imported transaction
@@ -39,6 +40,13 @@ import (
 // 	Description string             `json:"description"`
 // }
 
+const (
+	//GroupIncome defines the group of income transactions
+	GroupIncome string = "Income"
+	//GroupTransfer defines the group of transfers between accounts
+	GroupTransfer string = "Transfer"
+)
+
 //TransactionAgg holds metadata for aggregate data of transactions
 type TransactionAgg struct {
 	Year     int32   `json:"year"`
@@ -46,9 +54,87 @@ type TransactionAgg struct {
 	Group    string  `json:"group"`
 	Category string  `json:"category"`
 	Account  string  `json:"account"`
+	Dbcr     string  `json:"dbcr"`
+	Transfer string  `json:"transfer"`
 	Amount   float64 `json:"amount"`
 }
 
+//CashFlowGroup holds the amount of a group and category for a month
+type CashFlowGroup struct {
+	Type       string  `json:"type"`
+	Group      string  `json:"group"`
+	Category   string  `json:"category"`
+	Amount     float64 `json:"amount"`
+	PrevAmount float64 `json:"prevAmount"`
+}
+
+//CashFlow holds the income, expenses and savings for a month
+type CashFlow struct {
+	Year            int32            `json:"year"`
+	Month           int32            `json:"month"`
+	Income          float64          `json:"income"`
+	Expenses        float64          `json:"expenses"`
+	Transfers       float64          `json:"transfers"`
+	Net             float64          `json:"net"`
+	SavingsRate     float64          `json:"savingsRate"`
+	PrevIncome      float64          `json:"prevIncome"`
+	PrevExpenses    float64          `json:"prevExpenses"`
+	PrevNet         float64          `json:"prevNet"`
+	PrevSavingsRate float64          `json:"prevSavingsRate"`
+	Groups          []*CashFlowGroup `json:"groups"`
+}
+
+//CashFlowStatement holds the monthly cash flows and totals for a year
+type CashFlowStatement struct {
+	Year   int32       `json:"year"`
+	Months []*CashFlow `json:"months"`
+	Total  *CashFlow   `json:"total"`
+}
+
+//Subscription holds metadata for a recurring charge detected from transactions
+type Subscription struct {
+	Merchant    string       `json:"merchant"`
+	Group       string       `json:"group"`
+	Category    string       `json:"category"`
+	Account     string       `json:"account"`
+	Cadence     string       `json:"cadence"`
+	Count       int          `json:"count"`
+	Amount      float64      `json:"amount"`
+	AvgAmount   float64      `json:"avgAmount"`
+	PrevAmount  float64      `json:"prevAmount"`
+	PriceChange float64      `json:"priceChange"`
+	FirstDate   *time.Time   `json:"firstDate"`
+	LastDate    *time.Time   `json:"lastDate"`
+	NextDate    *time.Time   `json:"nextDate"`
+	Active      bool         `json:"active"`
+	Missed      []*time.Time `json:"missed"`
+	Duplicates  []*time.Time `json:"duplicates"`
+	Charges     Activities   `json:"charges"`
+}
+
+//Subscriptions holds an array of subscriptions
+type Subscriptions []*Subscription
+
+//Anomaly holds a month or a transaction that is unusual compared with its history
+type Anomaly struct {
+	Type       string    `json:"type"`
+	Reason     string    `json:"reason"`
+	Group      string    `json:"group"`
+	Category   string    `json:"category"`
+	Merchant   string    `json:"merchant"`
+	Year       int32     `json:"year"`
+	Month      int32     `json:"month"`
+	Amount     float64   `json:"amount"`
+	Mean       float64   `json:"mean"`
+	StdDev     float64   `json:"stdDev"`
+	ZScore     float64   `json:"zScore"`
+	Percentile float64   `json:"percentile"`
+	Activity   *Activity `json:"activity"`
+}
+
+//Anomalies holds an array of anomalies
+type Anomalies []*Anomaly
+
 // //Transactions holds an array of transactions
 // type Transactions []*Transaction
 
@@ -79,8 +165,9 @@ type TransactionAgg struct {
 // 	return txn
 // }
 
-//AggregateTransactions groups and aggreates the amount
-func (mdb *MongoDB) AggregateTransactions(ctx context.Context, fromDate *time.Time, toDate *time.Time) ([]TransactionAgg, error) {
+//AggregateTransactions groups and aggreates the amount, dbcr limits it to debits or credits.
+//Matched and confirmed transfers are left out unless transfers is set.
+func (mdb *MongoDB) AggregateTransactions(ctx context.Context, dbcr string, transfers bool, fromDate *time.Time, toDate *time.Time) ([]TransactionAgg, error) {
 	var pipeline []interface{}
 	// pipeline = make(map[string]interface{})
 	log.Printf("Transactions from: %v to: %v", fromDate, toDate)
@@ -90,7 +177,12 @@ func (mdb *MongoDB) AggregateTransactions(ctx context.Context, fromDate *time.Ti
 	user := UserFromCtx(ctx)
 	match["UID"] = bson.M{"$eq": user.UID}
 	match["actyType"] = "Transaction"
-	match["dbcr"] = "debit"
+	if len(dbcr) > 0 {
+		match["dbcr"] = dbcr
+	}
+	if !transfers {
+		match["transfer"] = bson.M{"$nin": bson.A{TransferMatched, TransferConfirmed}}
+	}
 
 	if fromDate != nil || toDate != nil {
 		if fromDate != nil && toDate != nil {
@@ -103,15 +195,18 @@ func (mdb *MongoDB) AggregateTransactions(ctx context.Context, fromDate *time.Ti
 		}
 	}
 
+	//split lines replace the group, category and amount of the transaction
 	query := bson.M{
 		"_id": bson.M{
 			"year":     bson.M{"$year": "$date"},
 			"month":    bson.M{"$month": "$date"},
-			"group":    "$group",
-			"category": "$category",
+			"group":    bson.M{"$ifNull": bson.A{"$splits.group", "$group"}},
+			"category": bson.M{"$ifNull": bson.A{"$splits.category", "$category"}},
 			"account":  "$account",
+			"dbcr":     "$dbcr",
+			"transfer": "$transfer",
 		},
-		"amount": bson.M{"$sum": "$amount"},
+		"amount": bson.M{"$sum": bson.M{"$ifNull": bson.A{"$splits.amount", "$amount"}}},
 	}
 
 	queryStage := bson.M{
@@ -122,9 +217,16 @@ func (mdb *MongoDB) AggregateTransactions(ctx context.Context, fromDate *time.Ti
 		"$match": match,
 	}
 
+	unwindStage := bson.M{
+		"$unwind": bson.M{
+			"path":                       "$splits",
+			"preserveNullAndEmptyArrays": true,
+		},
+	}
+
 	log.Println(match)
 
-	pipeline = append(pipeline, matchStage, queryStage)
+	pipeline = append(pipeline, matchStage, unwindStage, queryStage)
 	col := mdb.db.Collection(ACTVScol)
 	cursor, err := col.Aggregate(ctx, pipeline)
 	if err != nil {
@@ -141,6 +243,46 @@ func (mdb *MongoDB) AggregateTransactions(ctx context.Context, fromDate *time.Ti
 	return taggs, nil
 }
 
+//TransactionsActivities returns transaction activities for the date range sorted by date
+func (mdb *MongoDB) TransactionsActivities(ctx context.Context, dbcr string, fromDate *time.Time, toDate *time.Time) Activities {
+
+	var options = options.Find()
+	var query map[string]interface{}
+	query = make(map[string]interface{})
+
+	user := UserFromCtx(ctx)
+	query["UID"] = bson.M{"$eq": user.UID}
+	query["actyType"] = bson.M{"$eq": "Transaction"}
+	if len(dbcr) > 0 {
+		query["dbcr"] = bson.M{"$eq": dbcr}
+	}
+
+	if fromDate != nil && toDate != nil {
+		query["date"] = bson.M{"$gte": fromDate, "$lte": toDate}
+	} else if fromDate != nil {
+		query["date"] = bson.M{"$gte": fromDate}
+	} else if toDate != nil {
+		query["date"] = bson.M{"$lte": toDate}
+	}
+
+	options.SetSort(bson.D{{"date", 1}})
+	return mdb.getActivities(query, options)
+}
+
+//TransactionsTransfers returns the transactions with the transfer status
+func (mdb *MongoDB) TransactionsTransfers(ctx context.Context, status string) Activities {
+
+	var options = options.Find()
+	user := UserFromCtx(ctx)
+	query := bson.M{
+		"UID":      bson.M{"$eq": user.UID},
+		"actyType": bson.M{"$eq": "Transaction"},
+		"transfer": bson.M{"$eq": status},
+	}
+	options.SetSort(bson.D{{"date", -1}})
+	return mdb.getActivities(query, options)
+}
+
 func convertToTransactionAgg(results []map[string]interface{}) []TransactionAgg {
 
 	var taggs []TransactionAgg
@@ -172,6 +314,12 @@ func convertToTransactionAgg(results []map[string]interface{}) []TransactionAgg
 				tagg.Group = fmt.Sprintf("%s", entry["group"])
 				tagg.Category = fmt.Sprintf("%s", entry["category"])
 				tagg.Account = fmt.Sprintf("%s", entry["account"])
+				if dbcr, ok := entry["dbcr"].(string); ok {
+					tagg.Dbcr = dbcr
+				}
+				if transfer, ok := entry["transfer"].(string); ok {
+					tagg.Transfer = transfer
+				}
 			case float64:
 				tagg.Amount = entry
 			default:
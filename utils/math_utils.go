@@ -33,3 +33,74 @@ func RoundUp(x float64) float64 {
 func round(num float64) int {
 	return int(num + math.Copysign(0.5, num))
 }
+
+//Mean returns the average of the values
+func Mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+//StdDev returns the sample standard deviation of the values
+func StdDev(values []float64) float64 {
+	if len(values) < 2 {
+		return 0
+	}
+	mean := Mean(values)
+	var sum float64
+	for _, v := range values {
+		sum += (v - mean) * (v - mean)
+	}
+	return math.Sqrt(sum / float64(len(values)-1))
+}
+
+//Percentile returns the percent rank of the value within the values
+func Percentile(values []float64, value float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	below := 0
+	for _, v := range values {
+		if v < value {
+			below++
+		}
+	}
+	return float64(below) * 100 / float64(len(values))
+}
+
+//Covariance returns the sample covariance of the paired values
+func Covariance(x []float64, y []float64) float64 {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	if n < 2 {
+		return 0
+	}
+	mx := Mean(x[:n])
+	my := Mean(y[:n])
+	var sum float64
+	for i := 0; i < n; i++ {
+		sum += (x[i] - mx) * (y[i] - my)
+	}
+	return sum / float64(n-1)
+}
+
+//Correlation returns the Pearson correlation of the paired values
+func Correlation(x []float64, y []float64) float64 {
+	n := len(x)
+	if len(y) < n {
+		n = len(y)
+	}
+	sx := StdDev(x[:n])
+	sy := StdDev(y[:n])
+	if sx == 0 || sy == 0 {
+		return 0
+	}
+	return Covariance(x[:n], y[:n]) / (sx * sy)
+}
@@ -43,3 +43,43 @@ func createKeyWords(word string) []string {
 	// }
 	return keys
 }
+
+//NormalizeMerchant returns a merchant name stripped of processor prefixes, store numbers and punctuation
+func NormalizeMerchant(merchant string) string {
+
+	name := strings.ToUpper(strings.TrimSpace(merchant))
+
+	//payment processors prefix the merchant name with their own tag
+	for _, prefix := range []string{"SQ *", "SQ*", "TST*", "TST *", "PAYPAL *", "PP*", "CKO*", "SP *", "SP*", "DD *", "IN *"} {
+		if strings.HasPrefix(name, prefix) {
+			name = strings.TrimPrefix(name, prefix)
+			break
+		}
+	}
+
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			sb.WriteRune(r)
+		case r == '&':
+			sb.WriteRune(r)
+		default:
+			//digits, store numbers and punctuation split words
+			sb.WriteRune(' ')
+		}
+	}
+
+	words := strings.Fields(sb.String())
+	return strings.Join(words, " ")
+}
+
+//Contains returns true if the value is one of the values
+func Contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}